@@ -1,6 +1,7 @@
 package define
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go/ast"
@@ -16,122 +17,87 @@ import (
 type Config struct {
 	UseOffset bool
 	Context   build.Context
+
+	// MaxWorkers is the maximum number of files read and parsed
+	// concurrently, if zero runtime.NumCPU() is used.
+	MaxWorkers int
+
+	// MaxFileSize is the maximum size, in bytes, of a file that will be
+	// parsed, larger files are skipped.  If zero there is no limit.
+	MaxFileSize int64
+
+	// Overlay maps filenames to the contents of unsaved files, which are
+	// used in place of the files on disk when type checking the queried
+	// package and searching for definitions.  Files in the overlay that do
+	// not exist on disk are treated as part of the package in their
+	// directory.
+	//
+	// Imported packages are type checked from their compiled export data,
+	// so unsaved changes to them, such as a new exported function, are not
+	// seen by the type checker until the package is installed.
+	Overlay map[string][]byte
+
+	// Workspace is a list of directories searched recursively for packages
+	// when looking for references to an object, such as callers of a
+	// function.  The package of the queried file is always searched.
+	Workspace []string
+
+	// Assembly, if set, returns the assembly implementation, the TEXT
+	// directive in a ".s" file matching Context, of functions declared
+	// without a Go body.
+	Assembly bool
+
+	// Linkname, if set, follows go:linkname directives from functions
+	// declared without a Go body to their implementation.  The body-less
+	// declaration and the directive followed are recorded in the Hops of
+	// the Object returned by Object, Define only returns the position of
+	// the implementation.
+	Linkname bool
+
+	// Unadjusted, if set, reports positions in the files containing the
+	// definitions, ignoring //line directives.  Objects always record both
+	// the adjusted and unadjusted positions.
+	Unadjusted bool
+
+	// Finders maps object types to functions returning the PosFinder used
+	// to search for the definitions of objects of that type, such as the
+	// accessors of generated code.  Files are prefiltered using the
+	// Candidate method of the returned PosFinder.  If a function is not
+	// present or returns nil, the default PosFinder is used.
+	Finders map[Type]FinderFunc
 }
 
+// Define, returns the position and source of the definition of the object at
+// the cursor.  Declarations followed to reach the definition, such as
+// go:linkname directives, are only available from the Hops of Object.
 func (c *Config) Define(filename string, cursor int, src interface{}) (*Position, []byte, error) {
-	// TODO: Refactor this mess!
-	text, err := readSource(filename, src)
-	if err != nil {
-		return nil, nil, err
-	}
-	if err := checkSelection(text, cursor); err != nil {
-		return nil, nil, err
-	}
-	af, fset, err := parseFile(filename, text)
-	if err != nil {
-		return nil, nil, err
-	}
-	node, err := nodeAtOffset(af, fset, cursor)
-	if err != nil {
-		return nil, nil, err
-	}
-	ctx := newContext(filename, af, fset, &c.Context)
-	info := &types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
-		Uses: make(map[*ast.Ident]types.Object),
-	}
-	if _, ok := node.(*ast.SelectorExpr); ok {
-		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
-	}
-	conf := types.Config{}
-	if _, err := conf.Check(ctx.dirname, ctx.fset, ctx.files, info); err != nil {
-		// Return error only if missing type info.
-		if len(info.Defs) == 0 && len(info.Uses) == 0 {
-			return nil, nil, err
-		}
-	}
-	obj, sel, err := lookupType(node, info)
-	if err != nil {
-		return nil, nil, err
-	}
-	o, err := newObject(obj, sel)
-	if err != nil {
-		return nil, nil, err
-	}
-	f, err := o.Finder()
-	if err != nil {
-		return nil, nil, err
-	}
-	tp, objSrc, err := ctx.objectPosition(o.PkgPath, f)
+	return c.DefineContext(context.Background(), filename, cursor, src)
+}
+
+// DefineContext is like Define, but parsing and searching for the definition
+// stop when ctx is done.  Type checking cannot be interrupted, when ctx is done
+// it finishes in the background and its results are discarded.
+func (c *Config) DefineContext(ctx context.Context, filename string, cursor int, src interface{}) (*Position, []byte, error) {
+	o, objSrc, err := c.ObjectContext(ctx, filename, cursor, src)
 	if err != nil {
-		if o.pos.IsValid() {
-			if p := positionFor(o.pos, fset); p != nil {
-				return newPosition(*p), objSrc, nil
-			}
-		}
 		return nil, nil, err
 	}
-	return newPosition(*tp), objSrc, nil
+	return &o.Position, objSrc, nil
 }
 
 func (c *Config) Object(filename string, cursor int, src interface{}) (*Object, []byte, error) {
-	// TODO: Refactor this mess!
-	text, err := readSource(filename, src)
-	if err != nil {
-		return nil, nil, err
-	}
-	if err := checkSelection(text, cursor); err != nil {
-		return nil, nil, err
-	}
-	af, fset, err := parseFile(filename, text)
-	if err != nil {
-		return nil, nil, err
-	}
-	node, err := nodeAtOffset(af, fset, cursor)
-	if err != nil {
-		return nil, nil, err
-	}
-	ctx := newContext(filename, af, fset, &c.Context)
-	info := &types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
-		Uses: make(map[*ast.Ident]types.Object),
-	}
-	if _, ok := node.(*ast.SelectorExpr); ok {
-		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
-	}
-	conf := types.Config{}
-	if _, err := conf.Check(ctx.dirname, ctx.fset, ctx.files, info); err != nil {
-		// Return error only if missing type info.
-		if len(info.Defs) == 0 && len(info.Uses) == 0 {
-			return nil, nil, err
-		}
-	}
-	obj, sel, err := lookupType(node, info)
-	if err != nil {
-		return nil, nil, err
-	}
-	o, err := newObject(obj, sel)
-	if err != nil {
-		return nil, nil, err
-	}
-	f, err := o.Finder()
-	if err != nil {
-		return nil, nil, err
-	}
-	tp, objSrc, err := ctx.objectPosition(o.PkgPath, f)
+	return c.ObjectContext(context.Background(), filename, cursor, src)
+}
+
+// ObjectContext is like Object, but parsing and searching for the definition
+// stop when ctx is done.  Type checking cannot be interrupted, when ctx is done
+// it finishes in the background and its results are discarded.
+func (c *Config) ObjectContext(ctx context.Context, filename string, cursor int, src interface{}) (*Object, []byte, error) {
+	q, err := c.QueryContext(ctx, filename, cursor, src)
 	if err != nil {
-		if o.pos.IsValid() {
-			if p := positionFor(o.pos, fset); p != nil {
-				o.Position = Position(*p)
-				return o, objSrc, nil
-			}
-		}
 		return nil, nil, err
 	}
-	if tp != nil {
-		o.Position = Position(*tp)
-	}
-	return o, objSrc, nil
+	return q.Object()
 }
 
 var DefaultConfig = Config{
@@ -161,83 +127,56 @@ func NodeAtOffset(filename string, cursor int, src interface{}) (ast.Node, token
 	if err != nil {
 		return nil, pos, err
 	}
+	// Composite literal keys are returned as their identifier, doc links
+	// are only resolved by queries.
+	switch n := node.(type) {
+	case *compositeKey:
+		node = n.Ident
+	case *docLink:
+		return nil, pos, fmt.Errorf("no node at offset: %d", cursor)
+	}
 	pos = fset.Position(node.Pos())
 	return node, pos, nil
 }
 
 func ObjectOf(filename string, cursor int) (types.Object, *types.Selection, error) {
-	text, off, err := readSourceOffset(filename, cursor, nil)
+	q, err := queryOffset(filename, cursor)
 	if err != nil {
 		return nil, nil, err
 	}
-	if err := checkSelection(text, off); err != nil {
-		return nil, nil, err
-	}
-	af, fset, err := parseFile(filename, text)
-	if err != nil {
-		return nil, nil, err
-	}
-	node, err := nodeAtOffset(af, fset, cursor)
-	if err != nil {
-		return nil, nil, err
-	}
-	ctx := newContext(filename, af, fset, &build.Default)
-	info := &types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
-		Uses: make(map[*ast.Ident]types.Object),
-	}
-	if _, ok := node.(*ast.SelectorExpr); ok {
-		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
-	}
-	conf := types.Config{}
-	if _, err := conf.Check(ctx.dirname, ctx.fset, ctx.files, info); err != nil {
-		// Return error only if missing type info.
-		if len(info.Defs) == 0 && len(info.Uses) == 0 {
-			return nil, nil, err
-		}
-	}
-	return lookupType(node, info)
+	return q.TypesObject()
 }
 
 func FindObject(filename string, cursor int) (*Object, error) {
-	text, off, err := readSourceOffset(filename, cursor, nil)
+	q, err := queryOffset(filename, cursor)
 	if err != nil {
 		return nil, err
 	}
-	if err := checkSelection(text, off); err != nil {
-		return nil, err
-	}
-	af, fset, err := parseFile(filename, text)
+	obj, sel, err := q.TypesObject()
 	if err != nil {
 		return nil, err
 	}
-	node, err := nodeAtOffset(af, fset, cursor)
+	var o *Object
+	if sel != nil {
+		o, err = newSelector(sel)
+	} else {
+		o, err = newObject(obj, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
-	ctx := newContext(filename, af, fset, &build.Default)
-	info := &types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
-		Uses: make(map[*ast.Ident]types.Object),
-	}
-	if _, ok := node.(*ast.SelectorExpr); ok {
-		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
-	}
-	conf := types.Config{}
-	if _, err := conf.Check(ctx.dirname, ctx.fset, ctx.files, info); err != nil {
-		// Return error only if missing type info.
-		if len(info.Defs) == 0 && len(info.Uses) == 0 {
-			return nil, err
-		}
-	}
-	obj, sel, err := lookupType(node, info)
+	o.PkgPath = q.importPath(o.PkgPath)
+	return o, nil
+}
+
+// queryOffset, returns a Query using DefaultConfig for the character offset
+// cursor in filename.
+func queryOffset(filename string, cursor int) (*Query, error) {
+	text, off, err := readSourceOffset(filename, cursor, nil)
 	if err != nil {
 		return nil, err
 	}
-	if sel != nil {
-		return newSelector(sel)
-	}
-	return newObject(obj, nil)
+	return DefaultConfig.QueryContext(context.Background(), filename, off, text)
 }
 
 func newTypeInfo(node ast.Node) *types.Info {
@@ -245,8 +184,17 @@ func newTypeInfo(node ast.Node) *types.Info {
 		Defs: make(map[*ast.Ident]types.Object),
 		Uses: make(map[*ast.Ident]types.Object),
 	}
-	if _, ok := node.(*ast.SelectorExpr); ok {
+	switch node.(type) {
+	case *ast.SelectorExpr:
+		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
+	case *ast.ImportSpec:
+		info.Implicits = make(map[ast.Node]types.Object)
+	case *compositeKey:
+		info.Types = make(map[ast.Expr]types.TypeAndValue)
+	case *ast.GoStmt:
 		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
+	case *docLink:
+		info.Scopes = make(map[ast.Node]*types.Scope)
 	}
 	return &info
 }
@@ -256,12 +204,49 @@ func lookupType(node ast.Node, info *types.Info) (types.Object, *types.Selection
 	case *ast.Ident:
 		return info.ObjectOf(n), nil, nil
 	case *ast.ImportSpec:
-		return info.ObjectOf(n.Name), nil, nil
+		if n.Name != nil {
+			return info.ObjectOf(n.Name), nil, nil
+		}
+		if obj := info.Implicits[n]; obj != nil {
+			return obj, nil, nil
+		}
+		return nil, nil, fmt.Errorf("no package for import: %s", n.Path.Value)
 	case *ast.SelectorExpr:
 		if sel, ok := info.Selections[n]; ok {
 			return sel.Obj(), sel, nil
 		}
 		return info.ObjectOf(n.Sel), nil, nil
+	case *ast.BranchStmt:
+		if n.Label != nil {
+			return info.Uses[n.Label], nil, nil
+		}
+		return nil, nil, fmt.Errorf("no label for branch statement: %s", n.Tok)
+	case *ast.GoStmt:
+		// Resolve the function started by the go statement.
+		switch fn := unparen(n.Call.Fun).(type) {
+		case *ast.Ident, *ast.SelectorExpr:
+			return lookupType(fn, info)
+		}
+		return nil, nil, fmt.Errorf("unexpected go statement call: %#v", n.Call.Fun)
+	case *docLink:
+		obj, err := lookupDocLink(n, info)
+		return obj, nil, err
+	case *compositeKey:
+		if obj := info.ObjectOf(n.Ident); obj != nil {
+			return obj, nil, nil
+		}
+		// Keyed fields may not be recorded, lookup the field using the
+		// type of the literal, which is also recorded for elided types.
+		if tv, ok := info.Types[n.Lit]; ok && tv.Type != nil {
+			if st, ok := derefType(tv.Type).Underlying().(*types.Struct); ok {
+				for i := 0; i < st.NumFields(); i++ {
+					if f := st.Field(i); f.Name() == n.Name {
+						return f, nil, nil
+					}
+				}
+			}
+		}
+		return nil, nil, fmt.Errorf("no field for composite literal key: %s", n.Name)
 	case *ast.StructType:
 		return nil, nil, fmt.Errorf("unexpected struct: %#v", node)
 	default:
@@ -350,6 +335,17 @@ func byteOffset(src []byte, off int) int {
 	return -1
 }
 
+// readSource, is like readSource but prefers the overlay contents of filename
+// when src is nil.
+func (c *Config) readSource(filename string, src interface{}) ([]byte, error) {
+	if src == nil {
+		if b, ok := newOverlay(c.Overlay).file(filename); ok {
+			return b, nil
+		}
+	}
+	return readSource(filename, src)
+}
+
 func readSource(filename string, src interface{}) ([]byte, error) {
 	switch s := src.(type) {
 	case nil:
@@ -0,0 +1,31 @@
+package define
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestNodeAtOffset(t *testing.T) {
+	path, src, off := testSource(t, "query/query.go", "A: 1")
+	node, _, err := NodeAtOffset(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := node.(*ast.Ident); !ok || id.Name != "A" {
+		t.Errorf("got node %#v, want identifier A", node)
+	}
+}
+
+func TestNodeAtOffsetDocLink(t *testing.T) {
+	path, src, off := testSource(t, "query/query.go", "Sum].")
+	if node, _, err := NodeAtOffset(path, off, src); err == nil {
+		t.Errorf("got node %#v for doc link, want error", node)
+	}
+	o, _, err := testConfig(t).Object(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Name != "Sum" || o.ObjType != Func {
+		t.Errorf("got %s %s for doc link, want Func Sum", o.ObjType, o.Name)
+	}
+}
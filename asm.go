@@ -0,0 +1,141 @@
+package define
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// followAsm, returns the position of the assembly implementation of function
+// o, if assembly lookups are enabled and the function declared at pos does not
+// have a Go body.  Otherwise, pos and src are returned unchanged.
+func (c *searchContext) followAsm(o *Object, pos *token.Position, src []byte) (*token.Position, []byte) {
+	if !c.asm || !c.bodylessFunc(o, pos, src) {
+		return pos, src
+	}
+	if p, s := c.asmPosition(filepath.Dir(pos.Filename), o.Name); p != nil {
+		return p, s
+	}
+	return pos, src
+}
+
+// bodylessFunc, reports if function o is declared at pos without a Go body,
+// src is the source of the file containing pos and is read if nil.
+func (c *searchContext) bodylessFunc(o *Object, pos *token.Position, src []byte) bool {
+	if o.ObjType != Func || pos == nil || pos.Filename == "" {
+		return false
+	}
+	b := src
+	if pos.Filename == c.filename {
+		b = c.src
+	}
+	if b == nil {
+		var err error
+		if b, err = c.readFile(pos.Filename); err != nil {
+			return false
+		}
+	}
+	return isBodyless(pos.Filename, b, o.Name)
+}
+
+// isBodyless, reports if function name is declared without a body in src.
+func isBodyless(filename string, src []byte, name string) bool {
+	af, _ := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+	if af == nil {
+		return false
+	}
+	for _, d := range af.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+			return fn.Body == nil
+		}
+	}
+	return false
+}
+
+// asmPosition, returns the position of the TEXT directive for function name
+// in the assembly files of directory dir that match the build context, such as
+// "TEXT ·name(SB)" in "name_amd64.s" when GOARCH is "amd64".
+func (c *searchContext) asmPosition(dir, name string) (*token.Position, []byte) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, nil
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, nil
+	}
+	sort.Strings(names)
+	for _, s := range names {
+		if !hasSuffix(s, ".s") {
+			continue
+		}
+		if ok, _ := c.bctx.MatchFile(dir, s); !ok {
+			continue
+		}
+		path := filepath.Join(dir, s)
+		src, err := c.readFile(path)
+		if err != nil {
+			continue
+		}
+		if off := asmTextOffset(src, name); off != -1 {
+			return offsetPosition(path, src, off), src
+		}
+	}
+	return nil, nil
+}
+
+// asmTextOffset, returns the offset of the symbol of the TEXT directive for
+// function name in src, or -1 if not found.
+func asmTextOffset(src []byte, name string) int {
+	const dot = "·"
+	off := 0
+	for len(src) != 0 {
+		line := src
+		if n := bytes.IndexByte(src, '\n'); n != -1 {
+			line = src[:n+1]
+		}
+		trimmed := bytes.TrimLeft(line, " \t")
+		if bytes.HasPrefix(trimmed, []byte("TEXT")) {
+			rest := trimmed[len("TEXT"):]
+			sym := bytes.TrimLeft(rest, " \t")
+			if len(sym) != len(rest) {
+				if n := bytes.Index(sym, []byte("(SB)")); n != -1 {
+					s := string(sym[:n])
+					if i := strings.LastIndex(s, dot); i != -1 {
+						s = s[i+len(dot):]
+						if j := strings.IndexByte(s, '<'); j != -1 {
+							s = s[:j] // ABI selector
+						}
+						if s == name {
+							return off + len(line) - len(sym)
+						}
+					}
+				}
+			}
+		}
+		off += len(line)
+		src = src[len(line):]
+	}
+	return -1
+}
+
+// offsetPosition, returns the Position of byte offset off in src.
+func offsetPosition(filename string, src []byte, off int) *token.Position {
+	line := 1 + bytes.Count(src[:off], []byte{'\n'})
+	col := off + 1
+	if n := bytes.LastIndexByte(src[:off], '\n'); n != -1 {
+		col = off - n
+	}
+	return &token.Position{
+		Filename: filename,
+		Offset:   off,
+		Line:     line,
+		Column:   col,
+	}
+}
@@ -0,0 +1,271 @@
+package define
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/types"
+)
+
+// CallGroup, is a group of call sites belonging to a single function.  For
+// callers Func is the calling function and for callees Func is the called
+// function.
+type CallGroup struct {
+	Func  Object     `json:"func"`
+	Calls []Position `json:"calls"` // call sites
+}
+
+func (c *Config) Callers(filename string, cursor int, src interface{}) ([]CallGroup, error) {
+	return c.CallersContext(context.Background(), filename, cursor, src)
+}
+
+// CallersContext, returns the static call sites of the function or method at
+// the cursor, grouped by the calling function.  Calls in the initializers of
+// package level variables are grouped by the variable.  The package of filename
+// and the packages of the Config's Workspace are searched.
+func (c *Config) CallersContext(ctx context.Context, filename string, cursor int, src interface{}) ([]CallGroup, error) {
+	q, err := c.QueryContext(ctx, filename, cursor, src)
+	if err != nil {
+		return nil, err
+	}
+	return q.Callers()
+}
+
+// Callers, is like Config.CallersContext for the object at the cursor of the
+// Query.
+func (q *Query) Callers() ([]CallGroup, error) {
+	fn, err := q.funcObject()
+	if err != nil {
+		return nil, err
+	}
+	target := q.sc.objectKey(fn)
+	var groups callGroups
+	add := func(p *typedPackage) {
+		walkCalls(p.files, func(name, id *ast.Ident) {
+			callee, ok := p.info.Uses[id].(*types.Func)
+			if !ok || q.sc.objectKey(callee) != target {
+				return
+			}
+			if caller := p.info.Defs[name]; caller != nil {
+				groups.add(q.ctx, q.sc, p, caller, id.Pos())
+			}
+		})
+	}
+	add(q.queryPackage())
+	for _, dir := range q.sc.workspaceDirs(q.conf.Workspace, q.sc.dirname) {
+		p, err := q.sc.checkPackage(q.ctx, dir)
+		if err != nil {
+			if q.ctx.Err() != nil {
+				return nil, q.ctx.Err()
+			}
+			continue
+		}
+		add(p)
+	}
+	return groups.list, nil
+}
+
+func (c *Config) Callees(filename string, cursor int, src interface{}) ([]CallGroup, error) {
+	return c.CalleesContext(context.Background(), filename, cursor, src)
+}
+
+// CalleesContext, returns the static call sites within the body of the
+// function or method at the cursor, grouped by the called function.
+func (c *Config) CalleesContext(ctx context.Context, filename string, cursor int, src interface{}) ([]CallGroup, error) {
+	q, err := c.QueryContext(ctx, filename, cursor, src)
+	if err != nil {
+		return nil, err
+	}
+	return q.Callees()
+}
+
+// Callees, is like Config.CalleesContext for the object at the cursor of the
+// Query.
+func (q *Query) Callees() ([]CallGroup, error) {
+	fn, err := q.funcObject()
+	if err != nil {
+		return nil, err
+	}
+	p := q.queryPackage()
+	if fn.Pkg() != q.pkg {
+		dir, err := q.sc.pkgPath(q.sc.pkgKey(fn.Pkg()))
+		if err != nil {
+			return nil, err
+		}
+		if p, err = q.sc.checkPackage(q.ctx, dir); err != nil {
+			return nil, err
+		}
+	}
+	decl := p.funcDecl(q.sc, p.callKey(q.sc, fn))
+	if decl == nil || decl.Body == nil {
+		return nil, fmt.Errorf("no body found for function: %s", fn.Name())
+	}
+	var groups callGroups
+	inspectCalls(decl.Body, func(id *ast.Ident) {
+		if callee, ok := p.info.Uses[id].(*types.Func); ok {
+			groups.add(q.ctx, q.sc, p, callee, id.Pos())
+		}
+	})
+	return groups.list, nil
+}
+
+func (q *Query) funcObject() (*types.Func, error) {
+	if err := q.checkObject(); err != nil {
+		return nil, err
+	}
+	fn, ok := q.obj.(*types.Func)
+	if !ok {
+		return nil, fmt.Errorf("not a function or method: %s", q.obj.Name())
+	}
+	return fn, nil
+}
+
+// funcDecl, returns the declaration of the function with key.
+func (p *typedPackage) funcDecl(c *searchContext, key string) *ast.FuncDecl {
+	for _, af := range p.files {
+		for _, d := range af.Decls {
+			decl, ok := d.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if obj := p.info.Defs[decl.Name]; obj != nil && p.callKey(c, obj) == key {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// file, returns the file of p containing pos.
+func (p *typedPackage) file(pos token.Pos) *ast.File {
+	f := p.fset.File(pos)
+	for _, af := range p.files {
+		if f != nil && p.fset.File(af.Pos()) == f {
+			return af
+		}
+	}
+	return nil
+}
+
+// callKey, returns the objectKey of function or variable obj of p.  Init
+// functions, of which a package may declare several, are distinguished by
+// their position.
+func (p *typedPackage) callKey(c *searchContext, obj types.Object) string {
+	key := c.objectKey(obj)
+	if fn, ok := obj.(*types.Func); ok && fn.Name() == "init" && fn.Pkg() == p.pkg {
+		if sig, ok := fn.Type().(*types.Signature); ok && sig.Recv() == nil {
+			key += "@" + p.fset.Position(fn.Pos()).String()
+		}
+	}
+	return key
+}
+
+// callGroups, groups call sites by function preserving the order in which
+// functions are first seen.
+type callGroups struct {
+	list  []CallGroup
+	index map[string]int
+}
+
+func (g *callGroups) add(ctx context.Context, c *searchContext, p *typedPackage, fn types.Object, pos token.Pos) {
+	key := p.callKey(c, fn)
+	n, ok := g.index[key]
+	if !ok {
+		o := callObject(fn)
+		o.PkgPath = c.pkgKey(fn.Pkg())
+		// Only objects declared in p have positions in p.fset, others
+		// are searched for.
+		if fn.Pkg() == p.pkg {
+			c.setFilePosition(o, fn.Pos(), p.fset, p.file(fn.Pos()))
+		} else if fn.Pkg() != nil {
+			if tp, src, err := c.findObject(ctx, o); err == nil && tp != nil {
+				c.setPosition(o, *tp, src)
+			}
+		}
+		if g.index == nil {
+			g.index = make(map[string]int)
+		}
+		n = len(g.list)
+		g.index[key] = n
+		g.list = append(g.list, CallGroup{Func: *o})
+	}
+	if tp := c.resultPosition(pos, p.fset); tp != nil {
+		g.list[n].Calls = append(g.list[n].Calls, Position(*tp))
+	}
+}
+
+// walkCalls, calls fn with the name of the enclosing function declaration or
+// package level variable and the called identifier of each call expression in
+// files.
+func walkCalls(files []*ast.File, fn func(name, id *ast.Ident)) {
+	for _, af := range files {
+		for _, d := range af.Decls {
+			switch decl := d.(type) {
+			case *ast.FuncDecl:
+				if decl.Body == nil {
+					continue
+				}
+				inspectCalls(decl.Body, func(id *ast.Ident) {
+					fn(decl.Name, id)
+				})
+			case *ast.GenDecl:
+				if decl.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					vs := spec.(*ast.ValueSpec)
+					for i, x := range vs.Values {
+						// Values of multi-value assignments are
+						// grouped by the first name.
+						name := vs.Names[0]
+						if len(vs.Names) == len(vs.Values) {
+							name = vs.Names[i]
+						}
+						inspectCalls(x, func(id *ast.Ident) {
+							fn(name, id)
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+// callObject, returns the Object for the calling or called function or
+// variable fn.
+func callObject(fn types.Object) *Object {
+	if v, ok := fn.(*types.Var); ok {
+		o := &Object{Name: v.Name(), ObjType: Var, pos: v.Pos()}
+		o.setPkg(v.Pkg())
+		return o
+	}
+	o, _ := newObject(fn, nil)
+	return o
+}
+
+// inspectCalls, calls fn with the called identifier of each call expression
+// in node.
+func inspectCalls(node ast.Node, fn func(id *ast.Ident)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if id := calledIdent(call.Fun); id != nil {
+				fn(id)
+			}
+		}
+		return true
+	})
+}
+
+func calledIdent(x ast.Expr) *ast.Ident {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x
+	case *ast.SelectorExpr:
+		return x.Sel
+	case *ast.ParenExpr:
+		return calledIdent(x.X)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package define
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCallersInit(t *testing.T) {
+	conf := testConfig(t)
+	path, src, off := testSource(t, "calls/calls.go", "Helper() string")
+	groups, err := conf.Callers(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want one per init function: %+v", len(groups), groups)
+	}
+	for i, line := range []int{5, 7} {
+		if f := groups[i].Func; f.Name != "init" || f.Position.Line != line {
+			t.Errorf("group %d: got %s at line %d, want init at line %d",
+				i, f.Name, f.Position.Line, line)
+		}
+	}
+}
+
+func TestCalleesPosition(t *testing.T) {
+	conf := testConfig(t)
+	path, src, off := testSource(t, "calls/calls.go", "Helper() string")
+	groups, err := conf.Callees(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1: %+v", len(groups), groups)
+	}
+	f := groups[0].Func
+	if f.Name != "ToUpper" || f.PkgPath != "strings" {
+		t.Fatalf("got %s.%s, want strings.ToUpper", f.PkgPath, f.Name)
+	}
+	if filepath.Base(f.Position.Filename) != "strings.go" || f.Position.Line == 0 {
+		t.Errorf("got position %+v, want the declaration in strings.go", f.Position)
+	}
+}
@@ -0,0 +1,78 @@
+package define
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"unicode/utf8"
+)
+
+// hasIdents, reports if src contains the identifiers names in order, ignoring
+// comments and string literals.  Sources without a whole word match for every
+// name are rejected before scanning.  Empty names are ignored.
+func hasIdents(src []byte, names ...string) bool {
+	var want []string
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if !containsWord(src, name) {
+			return false
+		}
+		want = append(want, name)
+	}
+	if len(want) == 0 {
+		return true
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	i := 0
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return false
+		}
+		if tok == token.IDENT && lit == want[i] {
+			if i++; i == len(want) {
+				return true
+			}
+		}
+	}
+}
+
+// containsWord, reports if src contains word not adjacent to other identifier
+// characters.
+func containsWord(src []byte, word string) bool {
+	w := []byte(word)
+	for off := 0; off < len(src); {
+		n := bytes.Index(src[off:], w)
+		if n == -1 {
+			return false
+		}
+		start := off + n
+		end := start + len(w)
+		if !identBefore(src[:start]) && !identAfter(src[end:]) {
+			return true
+		}
+		off = start + 1
+	}
+	return false
+}
+
+func identBefore(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRune(b)
+	return isIdentRune(r)
+}
+
+func identAfter(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeRune(b)
+	return isIdentRune(r)
+}
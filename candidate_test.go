@@ -0,0 +1,60 @@
+package define
+
+import "testing"
+
+func TestContainsWord(t *testing.T) {
+	tests := []struct {
+		src  string
+		word string
+		want bool
+	}{
+		{"func Foo() {}", "Foo", true},
+		{"func FooBar() {}", "Foo", false},
+		{"func BarFoo() {}", "Foo", false},
+		{"func Foo_() {}", "Foo", false},
+		{"func Foo2() {}", "Foo", false},
+		{"x.Foo(y)", "Foo", true},
+		{"FooBar Foo", "Foo", true}, // second occurrence
+		{"Foo", "Foo", true},
+		{"äFoo", "Foo", false}, // Unicode letters are identifier characters
+		{"Fooé", "Foo", false},
+		{"·Foo(SB)", "Foo", true}, // middle dot is not
+		{"", "Foo", false},
+	}
+	for _, test := range tests {
+		if got := containsWord([]byte(test.src), test.word); got != test.want {
+			t.Errorf("containsWord(%q, %q) = %t, want %t", test.src, test.word,
+				got, test.want)
+		}
+	}
+}
+
+func TestHasIdents(t *testing.T) {
+	tests := []struct {
+		src   string
+		names []string
+		want  bool
+	}{
+		{"func Foo() {}", []string{"Foo"}, true},
+		{"// Foo\npackage p", []string{"Foo"}, false},
+		{"/* Foo */ package p", []string{"Foo"}, false},
+		{"package p\nvar s = \"Foo\"", []string{"Foo"}, false},
+		{"package p\nvar s = `Foo`", []string{"Foo"}, false},
+		{"package p\nvar r = 'F'", []string{"F"}, false},
+		{"package p // Foo\nfunc Foo() {}", []string{"Foo"}, true},
+		{"func FooBar() {}", []string{"Foo"}, false},
+		{"func (T) M() {}", []string{"T", "M"}, true},
+		{"func M() {}\ntype T int", []string{"T", "M"}, false}, // out of order
+		{"func (T) Other() {}\nfunc M() {}", []string{"T", "M"}, true},
+		{"func (T) M() {}", []string{"", "M"}, true}, // empty names are ignored
+		{"package p", []string{""}, true},
+		{"package p", nil, true},
+		{"func (é) ü() {}", []string{"é", "ü"}, true},
+	}
+	for _, test := range tests {
+		if got := hasIdents([]byte(test.src), test.names...); got != test.want {
+			t.Errorf("hasIdents(%q, %q) = %t, want %t", test.src, test.names,
+				got, test.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package define
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/types"
+)
+
+// docLink, is a doc link, such as [Name] or [pkg.Type.Method], or a bare
+// identifier within a comment.
+type docLink struct {
+	File    *ast.File
+	Text    string // link text, without brackets
+	TextPos token.Pos
+	Bare    bool // not enclosed in brackets
+}
+
+func (d *docLink) Pos() token.Pos { return d.TextPos }
+func (d *docLink) End() token.Pos { return d.TextPos + token.Pos(len(d.Text)) }
+
+// docLinkAt, returns the doc link or identifier at offset, if offset is
+// within a comment.
+func docLinkAt(af *ast.File, file *token.File, offset int) *docLink {
+	pos := file.Pos(offset)
+	for _, cg := range af.Comments {
+		if pos < cg.Pos() || cg.End() <= pos {
+			continue
+		}
+		for _, c := range cg.List {
+			if c.Pos() <= pos && pos < c.End() {
+				return parseDocLink(af, c, int(pos-c.Pos()))
+			}
+		}
+	}
+	return nil
+}
+
+// parseDocLink, returns the doc link at offset off in comment c.
+func parseDocLink(af *ast.File, c *ast.Comment, off int) *docLink {
+	text := c.Text
+	// Bracketed link on the same line as the offset.
+	start := strings.LastIndexAny(text[:off+1], "[]\n")
+	if start != -1 && text[start] == '[' {
+		if n := strings.IndexAny(text[start:], "]\n"); n != -1 && text[start+n] == ']' {
+			link := text[start+1 : start+n]
+			pos := start + 1
+			if strings.HasPrefix(link, "*") {
+				// Pointer links, such as [*bytes.Buffer].
+				link = link[1:]
+				pos++
+			}
+			if isDocLink(link) {
+				return &docLink{
+					File:    af,
+					Text:    link,
+					TextPos: c.Pos() + token.Pos(pos),
+				}
+			}
+		}
+	}
+	// Bare identifier.
+	i, j := off, off
+	for i > 0 {
+		r, n := utf8.DecodeLastRuneInString(text[:i])
+		if !isIdentRune(r) {
+			break
+		}
+		i -= n
+	}
+	for j < len(text) {
+		r, n := utf8.DecodeRuneInString(text[j:])
+		if !isIdentRune(r) {
+			break
+		}
+		j += n
+	}
+	if i == j || !isIdentifier(text[i:j]) {
+		return nil
+	}
+	return &docLink{
+		File:    af,
+		Text:    text[i:j],
+		TextPos: c.Pos() + token.Pos(i),
+		Bare:    true,
+	}
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isDocLink, reports if s is a valid doc link: an optional import path followed
+// by dot separated identifiers.  The last element of an import path may contain
+// dots, such as "gopkg.in/yaml.v2.Node".
+func isDocLink(s string) bool {
+	max := 3
+	if n := strings.LastIndex(s, "/"); n != -1 {
+		s = s[n+1:]
+		max = -1
+	}
+	parts := strings.Split(s, ".")
+	for _, p := range parts {
+		if !isIdentifier(p) {
+			return false
+		}
+	}
+	return len(parts) != 0 && (max == -1 || len(parts) <= max)
+}
+
+// lookupDocLink, resolves doc link d using the scope of its file.
+func lookupDocLink(d *docLink, info *types.Info) (types.Object, error) {
+	scope := info.Scopes[d.File]
+	if scope == nil {
+		return nil, fmt.Errorf("no scope for file: %s", d.File.Name.Name)
+	}
+	var parts []string
+	text := d.Text
+	if n := strings.LastIndex(text, "/"); n != -1 {
+		// Import path, match the longest import path of the file that
+		// is followed by a dot, as the path may contain dots.
+		var pkg *types.Package
+		for _, name := range scope.Names() {
+			pn, ok := scope.Lookup(name).(*types.PkgName)
+			if !ok {
+				continue
+			}
+			p := pn.Imported()
+			path := p.Path()
+			if len(text) > len(path) && text[len(path)] == '.' &&
+				strings.HasPrefix(text, path) &&
+				(pkg == nil || len(path) > len(pkg.Path())) {
+				pkg = p
+			}
+		}
+		if pkg == nil {
+			return nil, fmt.Errorf("package not imported: %s", text[:n])
+		}
+		parts = strings.Split(text[len(pkg.Path())+1:], ".")
+		return lookupMember(pkg, pkg.Scope().Lookup(parts[0]), parts[1:], d.Text)
+	}
+	parts = strings.Split(text, ".")
+	_, obj := scope.LookupParent(parts[0], token.NoPos)
+	if pn, ok := obj.(*types.PkgName); ok && len(parts) > 1 {
+		pkg := pn.Imported()
+		return lookupMember(pkg, pkg.Scope().Lookup(parts[1]), parts[2:], d.Text)
+	}
+	if obj == nil && len(parts) > 1 {
+		// Links use the package name, which may differ from the import
+		// name.
+		pkg := importedPackage(scope, func(p *types.Package) bool {
+			return p.Name() == parts[0]
+		})
+		if pkg != nil {
+			return lookupMember(pkg, pkg.Scope().Lookup(parts[1]), parts[2:], d.Text)
+		}
+	}
+	var pkg *types.Package
+	if obj != nil {
+		pkg = obj.Pkg()
+	}
+	return lookupMember(pkg, obj, parts[1:], d.Text)
+}
+
+// importedPackage, returns the first package imported in file scope that
+// matches fn.
+func importedPackage(scope *types.Scope, fn func(*types.Package) bool) *types.Package {
+	for _, name := range scope.Names() {
+		if pn, ok := scope.Lookup(name).(*types.PkgName); ok && fn(pn.Imported()) {
+			return pn.Imported()
+		}
+	}
+	return nil
+}
+
+// lookupMember, returns the field or method of obj named by parts, or obj if
+// parts is empty.
+func lookupMember(pkg *types.Package, obj types.Object, parts []string, link string) (types.Object, error) {
+	if obj == nil {
+		return nil, fmt.Errorf("no object for doc link: %s", link)
+	}
+	if len(parts) == 0 {
+		return obj, nil
+	}
+	if _, ok := obj.(*types.TypeName); !ok || len(parts) != 1 {
+		return nil, fmt.Errorf("invalid doc link: %s", link)
+	}
+	m, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg, parts[0])
+	if m == nil {
+		return nil, fmt.Errorf("no field or method for doc link: %s", link)
+	}
+	return m, nil
+}
@@ -0,0 +1,219 @@
+package define
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (c *Config) EmbedFiles(filename string, cursor int, src interface{}) ([]Position, error) {
+	return c.EmbedFilesContext(context.Background(), filename, cursor, src)
+}
+
+// EmbedFilesContext, returns the files matched by the go:embed directive of the
+// variable at the cursor, or by the directive pattern at the cursor.  Patterns
+// are matched relative to the directory of filename.
+func (c *Config) EmbedFilesContext(ctx context.Context, filename string, cursor int, src interface{}) ([]Position, error) {
+	text, err := c.readSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	// Patterns may contain reserved Go tokens, so only check the bounds
+	// of the cursor.
+	if cursor < 0 || cursor >= len(text) {
+		return nil, errors.New("invalid selection: offset out of range")
+	}
+	af, fset, err := parseFile(filename, text)
+	if err != nil {
+		return nil, err
+	}
+	patterns := embedPatternsAt(af, fset.File(af.Pos()).Pos(cursor))
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no go:embed directive at offset: %d", cursor)
+	}
+	dir := filepath.Dir(filepath.Clean(filename))
+	seen := make(map[string]bool)
+	var names []string
+	for _, p := range patterns {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		files, err := expandEmbed(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range files {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	pos := make([]Position, len(names))
+	for i, name := range names {
+		pos[i] = Position{Filename: name, Line: 1, Column: 1}
+	}
+	return pos, nil
+}
+
+// embedPattern, is a pattern of a go:embed directive.
+type embedPattern struct {
+	pattern  string
+	pos, end token.Pos
+}
+
+// embedPatternsAt, returns the go:embed patterns for pos.  If pos is within a
+// pattern only that pattern is returned, if pos is on a directive or on the
+// name of an embedded variable all of its patterns are returned.
+func embedPatternsAt(af *ast.File, pos token.Pos) []string {
+	for _, d := range af.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			doc := vs.Doc
+			if doc == nil && !gd.Lparen.IsValid() {
+				doc = gd.Doc
+			}
+			if doc == nil {
+				continue
+			}
+			var all []string
+			for _, c := range doc.List {
+				patterns := parseEmbed(c)
+				if c.Pos() <= pos && pos < c.End() {
+					for _, p := range patterns {
+						if p.pos <= pos && pos < p.end {
+							return []string{p.pattern}
+						}
+					}
+					return embedStrings(patterns)
+				}
+				all = append(all, embedStrings(patterns)...)
+			}
+			for _, id := range vs.Names {
+				if id.Pos() <= pos && pos <= id.End() {
+					return all
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func embedStrings(patterns []embedPattern) []string {
+	s := make([]string, len(patterns))
+	for i, p := range patterns {
+		s[i] = p.pattern
+	}
+	return s
+}
+
+// parseEmbed, returns the patterns of go:embed directive c, patterns may be
+// quoted.
+func parseEmbed(c *ast.Comment) []embedPattern {
+	const prefix = "//go:embed"
+	text := c.Text
+	if !strings.HasPrefix(text, prefix) || len(text) == len(prefix) ||
+		(text[len(prefix)] != ' ' && text[len(prefix)] != '\t') {
+		return nil
+	}
+	var patterns []embedPattern
+	i := len(prefix)
+	for i < len(text) {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+		j := i
+		var p string
+		switch text[i] {
+		case '"', '`':
+			q := text[i]
+			for j = i + 1; j < len(text) && text[j] != q; j++ {
+				if q == '"' && text[j] == '\\' {
+					j++
+				}
+			}
+			if j < len(text) {
+				j++
+			}
+			s, err := strconv.Unquote(text[i:j])
+			if err != nil {
+				return patterns
+			}
+			p = s
+		default:
+			for j < len(text) && text[j] != ' ' && text[j] != '\t' {
+				j++
+			}
+			p = text[i:j]
+		}
+		patterns = append(patterns, embedPattern{
+			pattern: p,
+			pos:     c.Pos() + token.Pos(i),
+			end:     c.Pos() + token.Pos(j),
+		})
+		i = j
+	}
+	return patterns
+}
+
+// expandEmbed, returns the files in dir matched by go:embed pattern.  Matched
+// directories are included recursively, excluding files beginning with '.' or
+// '_' unless the pattern has the "all:" prefix.
+func expandEmbed(dir, pattern string) ([]string, error) {
+	all := strings.HasPrefix(pattern, "all:")
+	if all {
+		pattern = pattern[len("all:"):]
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("pattern %s: no matching files found", pattern)
+	}
+	var files []string
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, m)
+			continue
+		}
+		err = filepath.Walk(m, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != m && !all {
+				if name := fi.Name(); name[0] == '.' || name[0] == '_' {
+					if fi.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+			if fi.Mode().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -8,18 +8,25 @@ import (
 	"go/token"
 )
 
-type posFinder interface {
+// PosFinder, finds the position of a declaration in the files of a package.
+// Candidate reports if the source of a file may contain the declaration, only
+// candidate files are parsed and passed to Find.
+type PosFinder interface {
 	Candidate(b []byte) bool
 	Find(af *ast.File, fset *token.FileSet) *token.Position
 }
 
+// FinderFunc, returns the PosFinder used to search for object o, or nil to use
+// the default PosFinder for o.
+type FinderFunc func(o *Object) PosFinder
+
 // Finds top-level (global) declarations
 type declFinder struct {
 	Name string
 }
 
 func (f declFinder) Candidate(b []byte) bool {
-	return bytes.Contains(b, []byte(f.Name))
+	return hasIdents(b, f.Name)
 }
 
 func (f declFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
@@ -88,11 +95,10 @@ type methodFinder struct {
 	TypeName string
 }
 
+// Candidate, reports if b contains the type name followed by the method name,
+// as in the receiver and name of the method declaration.
 func (f methodFinder) Candidate(b []byte) bool {
-	if n := bytes.Index(b, []byte(f.TypeName)); n != -1 {
-		return bytes.Index(b[n:], []byte(f.Name)) != -1
-	}
-	return false
+	return hasIdents(b, f.TypeName, f.Name)
 }
 
 func (f methodFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
@@ -110,7 +116,6 @@ func (f methodFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
 type methodVisitor struct {
 	Name     string
 	TypeName string
-	recv     *recvVisitor
 	pos      token.Pos
 }
 
@@ -124,7 +129,7 @@ func (v *methodVisitor) Visit(node ast.Node) ast.Visitor {
 	}
 	n, ok := node.(*ast.FuncDecl)
 	if ok && n.Name != nil && n.Name.Name == v.Name && n.Recv != nil {
-		if v.methodOf(n.Recv) {
+		if methodOf(v.TypeName, n.Recv) {
 			v.pos = n.Pos()
 			return nil
 		}
@@ -132,46 +137,55 @@ func (v *methodVisitor) Visit(node ast.Node) ast.Visitor {
 	return v
 }
 
-func (v *methodVisitor) methodOf(list *ast.FieldList) bool {
-	if v.recv == nil {
-		v.recv = &recvVisitor{Name: v.TypeName}
-	} else {
-		v.recv.depth = 0
-		v.recv.found = false
+// methodOf, reports if receiver list is of the type named name.
+func methodOf(name string, list *ast.FieldList) bool {
+	if list == nil || len(list.List) != 1 {
+		return false
 	}
-	ast.Walk(v.recv, list)
-	return v.recv.found
+	return recvTypeName(list.List[0].Type) == name
 }
 
-// Find the name of a method reciever
-type recvVisitor struct {
-	Name  string // Type name
-	found bool
-	depth int
+// recvTypeName, returns the base type name of receiver type x, unwrapping
+// pointers, type parameters and parentheses, for example "T" for "*T",
+// "T[P]" and "(*T[K, V])".
+func recvTypeName(x ast.Expr) string {
+	// Receivers are unqualified, so this is the embedded type name.
+	if id := embeddedIdent(x); id != nil {
+		return id.Name
+	}
+	return ""
 }
 
-func methodOf(name string, list *ast.FieldList) bool {
-	if list == nil {
-		return false
-	}
-	rv := &recvVisitor{Name: name}
-	ast.Walk(rv, list)
-	return rv.found
+// Finds the methods of interface types
+type ifaceMethodFinder struct {
+	Name     string
+	TypeName string
+}
+
+func (f ifaceMethodFinder) Candidate(b []byte) bool {
+	return hasIdents(b, f.TypeName, f.Name)
 }
 
-func (v *recvVisitor) Visit(node ast.Node) ast.Visitor {
-	// TODO: MaxDepth can probably be reduced to 5
-	const MaxDepth = 10
-	if v.found || node == nil || v.depth > MaxDepth {
+func (f ifaceMethodFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
+	if af == nil || fset == nil {
 		return nil
 	}
-	id, ok := node.(*ast.Ident)
-	if ok && id.Name == v.Name {
-		v.found = true
+	ts := typeSpec(af, f.TypeName)
+	if ts == nil {
 		return nil
 	}
-	v.depth++
-	return v
+	it, ok := unparen(ts.Type).(*ast.InterfaceType)
+	if !ok || it.Methods == nil {
+		return nil
+	}
+	for _, m := range it.Methods.List {
+		for _, id := range m.Names {
+			if id.Name == f.Name {
+				return positionFor(id.Pos(), fset)
+			}
+		}
+	}
+	return nil
 }
 
 type typeVistor struct {
@@ -204,7 +218,7 @@ type varFinder struct {
 }
 
 func (f varFinder) Candidate(b []byte) bool {
-	return bytes.Contains(b, []byte(f.Name))
+	return hasIdents(b, f.Name)
 }
 
 func (f varFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
@@ -249,13 +263,11 @@ func (v *varVistor) Visit(node ast.Node) ast.Visitor {
 type fieldFinder struct {
 	Name   string
 	Parent string
+	Fields []string // anonymous struct fields enclosing the field, if known
 }
 
 func (f fieldFinder) Candidate(b []byte) bool {
-	if n := bytes.Index(b, []byte(f.Parent)); n != -1 {
-		return bytes.Index(b[n:], []byte(f.Name)) != -1
-	}
-	return false
+	return hasIdents(b, f.Parent, f.Name)
 }
 
 func (f fieldFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
@@ -266,44 +278,124 @@ func (f fieldFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
 	if f.Parent == "" {
 		panic("fieldFinder: parent is required")
 	}
-	v := fieldVisitor{
-		Name:   f.Name,
-		Parent: f.Parent,
+	st := typeStruct(af, f.Parent)
+	for _, name := range f.Fields {
+		if st == nil {
+			return nil
+		}
+		st = nestedStruct(st, name)
 	}
-	ast.Walk(&v, af)
-	return positionFor(v.pos, fset)
+	if st == nil {
+		return nil
+	}
+	if id := structField(st, f.Name, len(f.Fields) == 0); id != nil {
+		return positionFor(id.Pos(), fset)
+	}
+	return nil
 }
 
-// Finds struct fields
-type fieldVisitor struct {
-	Name   string
-	Parent string
-	pos    token.Pos
-	field  bool
+// typeSpec, returns the package level type spec of name in af, if any.
+func typeSpec(af *ast.File, name string) *ast.TypeSpec {
+	for _, d := range af.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == name {
+				return ts
+			}
+		}
+	}
+	return nil
 }
 
-func (v *fieldVisitor) Pos() token.Pos {
-	return v.pos
+// typeStruct, returns the struct type of the package level type name declared
+// in af, if any.
+func typeStruct(af *ast.File, name string) *ast.StructType {
+	if ts := typeSpec(af, name); ts != nil {
+		st, _ := unparen(ts.Type).(*ast.StructType)
+		return st
+	}
+	return nil
 }
 
-func (v *fieldVisitor) Visit(node ast.Node) ast.Visitor {
-	if node == nil || v.pos != token.NoPos {
+// nestedStruct, returns the anonymous struct type of field name of st.
+func nestedStruct(st *ast.StructType, name string) *ast.StructType {
+	for _, f := range st.Fields.List {
+		for _, id := range f.Names {
+			if id.Name == name {
+				return anonStruct(f.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// anonStruct, returns the anonymous struct of field type x, if any.
+func anonStruct(x ast.Expr) *ast.StructType {
+	x = unparen(x)
+	if star, ok := x.(*ast.StarExpr); ok {
+		x = unparen(star.X)
+	}
+	st, _ := x.(*ast.StructType)
+	return st
+}
+
+// structField, returns the identifier declaring field name of st, the type
+// name of embedded fields.  If nested is true the fields of anonymous structs
+// are searched if st does not declare the field.
+func structField(st *ast.StructType, name string, nested bool) *ast.Ident {
+	if st.Fields == nil {
 		return nil
 	}
-	if id, ok := node.(*ast.Ident); ok && id.Obj != nil {
-		switch id.Obj.Decl.(type) {
-		case *ast.TypeSpec:
-			v.field = (id.Name == v.Parent)
-		case *ast.Field:
-			if v.field && id.Name == v.Name {
-				if _, ok := id.Obj.Decl.(*ast.Field); ok {
-					v.pos = id.Pos()
-					return nil
-				}
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			if id := embeddedIdent(f.Type); id != nil && id.Name == name {
+				return id
+			}
+			continue
+		}
+		for _, id := range f.Names {
+			if id.Name == name {
+				return id
 			}
 		}
 	}
-	return v
+	if !nested {
+		return nil
+	}
+	for _, f := range st.Fields.List {
+		if ns := anonStruct(f.Type); ns != nil && len(f.Names) != 0 {
+			if id := structField(ns, name, true); id != nil {
+				return id
+			}
+		}
+	}
+	return nil
+}
+
+// embeddedIdent, returns the type name identifier of embedded field x, such
+// as "T" for "*pkg.T[P]".
+func embeddedIdent(x ast.Expr) *ast.Ident {
+	for {
+		switch t := x.(type) {
+		case *ast.ParenExpr:
+			x = t.X
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.IndexExpr:
+			x = t.X
+		case *ast.IndexListExpr:
+			x = t.X
+		case *ast.SelectorExpr:
+			return t.Sel
+		case *ast.Ident:
+			return t
+		default:
+			return nil
+		}
+	}
 }
 
 // Finds package doc file.
@@ -323,12 +415,13 @@ func (f docFinder) Candidate(b []byte) bool {
 	return false
 }
 
+// Returns the position of the package clause, if the file has a package
+// comment.
 func (d docFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
-	if af.Comments != nil && len(af.Comments) != 0 {
-		p := fset.Position(af.Comments[0].Pos())
-		return &p
+	if af == nil || fset == nil || af.Doc == nil {
+		return nil
 	}
-	return nil
+	return positionFor(af.Package, fset)
 }
 
 type offsetVisitor struct {
@@ -340,6 +433,11 @@ func (v offsetVisitor) found(start, end token.Pos) bool {
 	return start <= v.pos && v.pos <= end
 }
 
+// foundKeyword, reports if the offset is within keyword tok at pos.
+func (v offsetVisitor) foundKeyword(pos token.Pos, tok token.Token) bool {
+	return v.found(pos, pos+token.Pos(len(tok.String())))
+}
+
 func (v *offsetVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	if node == nil || v.node != nil {
 		return nil
@@ -355,6 +453,57 @@ func (v *offsetVisitor) Visit(node ast.Node) (w ast.Visitor) {
 		start = n.Sel.NamePos
 	case *ast.ImportSpec:
 		start = n.Pos()
+	case *ast.BranchStmt:
+		if v.foundKeyword(n.TokPos, n.Tok) || n.Label != nil && v.found(n.Label.Pos(), n.Label.End()) {
+			v.node = n
+			return nil
+		}
+		return v
+	case *ast.ReturnStmt:
+		if v.foundKeyword(n.Return, token.RETURN) {
+			v.node = n
+			return nil
+		}
+		return v
+	case *ast.DeferStmt:
+		if v.foundKeyword(n.Defer, token.DEFER) {
+			v.node = n
+			return nil
+		}
+		return v
+	case *ast.GoStmt:
+		if v.foundKeyword(n.Go, token.GO) {
+			v.node = n
+			return nil
+		}
+		return v
+	case *ast.FuncType:
+		if n.Func.IsValid() && v.foundKeyword(n.Func, token.FUNC) {
+			v.node = n
+			return nil
+		}
+		return v
+	case *ast.CompositeLit:
+		for _, elt := range n.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if id, ok := kv.Key.(*ast.Ident); ok && v.found(id.NamePos, id.End()) {
+				v.node = &compositeKey{Ident: id, Lit: n}
+				return nil
+			}
+		}
+		return v
+	case *ast.Field:
+		// Resolve positions inside a struct tag to the field.
+		if n.Tag != nil && v.found(n.Tag.Pos(), n.Tag.End()) {
+			if id := fieldIdent(n); id != nil {
+				v.node = id
+				return nil
+			}
+		}
+		return v
 	case *ast.StructType:
 		// TODO: Remove if unnecessary
 		if n.Fields == nil {
@@ -387,8 +536,25 @@ func (v *offsetVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	return v
 }
 
+// compositeKey, is the key of a keyed element of a composite literal.
+type compositeKey struct {
+	*ast.Ident
+	Lit *ast.CompositeLit
+}
+
+// fieldIdent, returns the name of field f, for embedded fields this is the
+// name of the embedded type.
+func fieldIdent(f *ast.Field) *ast.Ident {
+	if len(f.Names) != 0 {
+		return f.Names[0]
+	}
+	return embeddedIdent(f.Type)
+}
+
 // nodeAtOffset, returns the ast.Node for the given offset.
-// Node types: *ast.Ident, *ast.SelectorExpr, *ast.ImportSpec
+// Node types: *ast.Ident, *ast.SelectorExpr, *ast.ImportSpec, *ast.BranchStmt,
+// *ast.ReturnStmt, *ast.DeferStmt, *ast.GoStmt, *ast.FuncType, *compositeKey,
+// *docLink
 func nodeAtOffset(af *ast.File, fset *token.FileSet, offset int) (ast.Node, error) {
 	file := fset.File(af.Pos())
 	if file == nil {
@@ -398,6 +564,9 @@ func nodeAtOffset(af *ast.File, fset *token.FileSet, offset int) (ast.Node, erro
 	if offset < 0 || file.Size() < offset {
 		return nil, fmt.Errorf("invalid offset: %d", offset)
 	}
+	if d := docLinkAt(af, file, offset); d != nil {
+		return d, nil
+	}
 	v := &offsetVisitor{pos: file.Pos(offset)}
 	ast.Walk(v, af)
 	if v.node == nil {
@@ -406,13 +575,14 @@ func nodeAtOffset(af *ast.File, fset *token.FileSet, offset int) (ast.Node, erro
 	return v.node, nil
 }
 
-// positionFor, returns the Position for Pos p in FileSet fset.
+// positionFor, returns the Position for Pos p in FileSet fset, //line
+// directives are not applied.
 func positionFor(p token.Pos, fset *token.FileSet) *token.Position {
 	if p != token.NoPos && fset != nil {
 		if f := fset.File(p); f != nil {
 			// Prevent panic
 			if f.Base() <= int(p) && int(p) <= f.Base()+f.Size() {
-				p := f.Position(p)
+				p := f.PositionFor(p, false)
 				return &p
 			}
 		}
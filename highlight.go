@@ -0,0 +1,143 @@
+package define
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+type HighlightKind int
+
+const (
+	HighlightRead HighlightKind = iota
+	HighlightWrite
+	HighlightDecl
+)
+
+var highlightNames = [...]string{
+	"Read",
+	"Write",
+	"Decl",
+}
+
+func (k HighlightKind) String() string {
+	if 0 <= k && int(k) < len(highlightNames) {
+		return highlightNames[k]
+	}
+	return "Invalid"
+}
+
+// Highlight, is an occurrence of an object in a file.
+type Highlight struct {
+	Kind  HighlightKind `json:"kind"`
+	Start Position      `json:"start"`
+	End   Position      `json:"end"`
+}
+
+func (c *Config) Highlights(filename string, cursor int, src interface{}) ([]Highlight, error) {
+	return c.HighlightsContext(context.Background(), filename, cursor, src)
+}
+
+// HighlightsContext, returns the occurrences, in filename, of the object at the
+// cursor ordered by offset.
+func (c *Config) HighlightsContext(ctx context.Context, filename string, cursor int, src interface{}) ([]Highlight, error) {
+	q, err := c.QueryContext(ctx, filename, cursor, src)
+	if err != nil {
+		return nil, err
+	}
+	return q.Highlights()
+}
+
+// Highlights, returns the occurrences, in the queried file, of the object at
+// the cursor ordered by offset.
+func (q *Query) Highlights() ([]Highlight, error) {
+	if err := q.checkObject(); err != nil {
+		return nil, err
+	}
+	writes := assignedIdents(q.af)
+	var hs []Highlight
+	add := func(kind HighlightKind, n ast.Node) {
+		start := q.sc.resultPosition(n.Pos(), q.fset)
+		end := q.sc.resultPosition(n.End(), q.fset)
+		if start != nil && end != nil {
+			hs = append(hs, Highlight{
+				Kind:  kind,
+				Start: Position(*start),
+				End:   Position(*end),
+			})
+		}
+	}
+	inFile := func(n ast.Node) bool {
+		return q.af.Pos() <= n.Pos() && n.End() <= q.af.End()
+	}
+	for id, obj := range q.info.Defs {
+		if obj == q.obj && inFile(id) {
+			add(HighlightDecl, id)
+		}
+	}
+	for id, obj := range q.info.Uses {
+		if obj != q.obj || !inFile(id) {
+			continue
+		}
+		if writes[id] {
+			add(HighlightWrite, id)
+		} else {
+			add(HighlightRead, id)
+		}
+	}
+	// Unnamed imports are recorded as implicit objects.
+	for node, obj := range q.info.Implicits {
+		if spec, ok := node.(*ast.ImportSpec); ok && obj == q.obj && inFile(spec) {
+			add(HighlightDecl, spec.Path)
+		}
+	}
+	sort.Sort(byStart(hs))
+	return hs, nil
+}
+
+type byStart []Highlight
+
+func (h byStart) Len() int           { return len(h) }
+func (h byStart) Less(i, j int) bool { return h[i].Start.Offset < h[j].Start.Offset }
+func (h byStart) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// assignedIdents, returns the identifiers in af that are assigned to.  For
+// selectors the selected identifier is returned.
+func assignedIdents(af *ast.File) map[*ast.Ident]bool {
+	m := make(map[*ast.Ident]bool)
+	add := func(x ast.Expr) {
+		if id := assignedIdent(x); id != nil {
+			m[id] = true
+		}
+	}
+	ast.Inspect(af, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, x := range n.Lhs {
+				add(x)
+			}
+		case *ast.IncDecStmt:
+			add(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				add(n.Key)
+				add(n.Value)
+			}
+		}
+		return true
+	})
+	return m
+}
+
+func assignedIdent(x ast.Expr) *ast.Ident {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x
+	case *ast.SelectorExpr:
+		return x.Sel
+	case *ast.ParenExpr:
+		return assignedIdent(x.X)
+	}
+	return nil
+}
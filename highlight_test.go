@@ -0,0 +1,21 @@
+package define
+
+import "testing"
+
+func TestHighlightsLabel(t *testing.T) {
+	conf := testConfig(t)
+	path, src, off := testSource(t, "query/query.go", "loop\n\t\t}")
+	hs, err := conf.Highlights(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HighlightKind{HighlightDecl, HighlightRead}
+	if len(hs) != len(want) {
+		t.Fatalf("got %d highlights, want %d: %+v", len(hs), len(want), hs)
+	}
+	for i, h := range hs {
+		if h.Kind != want[i] {
+			t.Errorf("highlight %d: got %s, want %s", i, h.Kind, want[i])
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package define
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MarshalText, encodes unknown types as Invalid so that an Object can always
+// be encoded.
+func (t Type) MarshalText() ([]byte, error) {
+	if t < 0 || int(t) >= len(typeNames) {
+		t = Invalid
+	}
+	return []byte(typeNames[t]), nil
+}
+
+func (t *Type) UnmarshalText(text []byte) error {
+	n, err := parseName(typeNames[:], text)
+	if err != nil {
+		return fmt.Errorf("invalid Type: %s", err)
+	}
+	*t = Type(n)
+	return nil
+}
+
+func (k SelectionKind) MarshalText() ([]byte, error) {
+	if k < 0 || int(k) >= len(selectionNames) {
+		return nil, fmt.Errorf("invalid SelectionKind: %d", int(k))
+	}
+	return []byte(selectionNames[k]), nil
+}
+
+func (k *SelectionKind) UnmarshalText(text []byte) error {
+	n, err := parseName(selectionNames[:], text)
+	if err != nil {
+		return fmt.Errorf("invalid SelectionKind: %s", err)
+	}
+	*k = SelectionKind(n)
+	return nil
+}
+
+func (k HighlightKind) MarshalText() ([]byte, error) {
+	if k < 0 || int(k) >= len(highlightNames) {
+		return nil, fmt.Errorf("invalid HighlightKind: %d", int(k))
+	}
+	return []byte(highlightNames[k]), nil
+}
+
+func (k *HighlightKind) UnmarshalText(text []byte) error {
+	n, err := parseName(highlightNames[:], text)
+	if err != nil {
+		return fmt.Errorf("invalid HighlightKind: %s", err)
+	}
+	*k = HighlightKind(n)
+	return nil
+}
+
+func (k Key) MarshalText() ([]byte, error) {
+	if !k.IsValid() {
+		return nil, errors.New("invalid Key")
+	}
+	return []byte(k.String()), nil
+}
+
+func (k *Key) UnmarshalText(text []byte) error {
+	key, err := ParseKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
+
+// parseName, returns the index of text in names.
+func parseName(names []string, text []byte) (int, error) {
+	for i, s := range names {
+		if s == string(text) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown name: %q", text)
+}
+
+// jsonObject, is the JSON encoding of Object, which includes whether the
+// object is local.  Token positions are not encoded.
+type jsonObject struct {
+	object
+	Local bool `json:"local,omitempty"`
+}
+
+// object, has the fields of Object without its methods.
+type object Object
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonObject{object: object(o), Local: o.local})
+}
+
+func (o *Object) UnmarshalJSON(b []byte) error {
+	var v jsonObject
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*o = Object(v.object)
+	o.local = v.Local
+	return nil
+}
+
+// ResponseVersion, is the version of the JSON encoding of Response and of the
+// results it contains.
+const ResponseVersion = 1
+
+// Response, is a versioned JSON envelope for the result of a request or its
+// error.
+type Response struct {
+	Version int             `json:"version"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// NewResponse, returns a Response containing the JSON encoding of result, or
+// err if it is not nil.
+func NewResponse(result interface{}, err error) (*Response, error) {
+	r := &Response{Version: ResponseVersion}
+	if err != nil {
+		r.Error = err.Error()
+		return r, nil
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	r.Result = b
+	return r, nil
+}
+
+// Decode, decodes the result of r into v.  If r contains an error it is
+// returned, as is an error for an unsupported version.
+func (r *Response) Decode(v interface{}) error {
+	if r.Version != ResponseVersion {
+		return fmt.Errorf("unsupported response version: %d", r.Version)
+	}
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+	if len(r.Result) == 0 {
+		return errors.New("empty response")
+	}
+	return json.Unmarshal(r.Result, v)
+}
@@ -0,0 +1,111 @@
+package define
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestObjectJSON(t *testing.T) {
+	pos := Position{Filename: "/src/p/p.go", Offset: 40, Line: 3, Column: 6}
+	objs := []Object{
+		{
+			Name:      "Do",
+			Parent:    "Client",
+			PkgName:   "http",
+			PkgPath:   "net/http",
+			ObjType:   Method,
+			Position:  pos,
+			Selection: MethodValue,
+			Hops:      []Position{pos, pos},
+			FileRank:  &FileRank{Index: 2, Excluded: true, Test: true},
+			Adjusted:  pos,
+			Generated: true,
+		},
+		{
+			Name:     "Field",
+			Parent:   "Outer",
+			ObjType:  Var,
+			IsField:  true,
+			Fields:   []string{"Inner"},
+			Position: pos,
+		},
+		{Name: "loop", ObjType: Label, local: true},
+	}
+	for _, o := range objs {
+		b, err := json.Marshal(o)
+		if err != nil {
+			t.Fatalf("%s: %s", o.Name, err)
+		}
+		var got Object
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%s: %s", o.Name, err)
+		}
+		if !reflect.DeepEqual(got, o) {
+			t.Errorf("%s: got %+v, want %+v", o.Name, got, o)
+		}
+	}
+}
+
+func TestObjectJSONInvalidType(t *testing.T) {
+	b, err := json.Marshal(Object{Name: "x", ObjType: Type(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o Object
+	if err := json.Unmarshal(b, &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.ObjType != Invalid {
+		t.Errorf("got type %s, want Invalid", o.ObjType)
+	}
+	if err := json.Unmarshal([]byte(`{"objType":"Unknown"}`), &o); err == nil {
+		t.Error("expected error decoding unknown type")
+	}
+}
+
+func TestResponseJSON(t *testing.T) {
+	want := []CallGroup{{
+		Func:  Object{Name: "F", PkgPath: "p", ObjType: Func},
+		Calls: []Position{{Filename: "p.go", Offset: 10, Line: 2, Column: 3}},
+	}}
+	r, err := NewResponse(want, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Response
+	if err := json.Unmarshal(b, &dec); err != nil {
+		t.Fatal(err)
+	}
+	var got []CallGroup
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	r, err = NewResponse(nil, errors.New("no object found"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = json.Marshal(r); err != nil {
+		t.Fatal(err)
+	}
+	dec = Response{}
+	if err := json.Unmarshal(b, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&got); err == nil || err.Error() != "no object found" {
+		t.Errorf("got error %v, want %q", err, "no object found")
+	}
+	dec.Version = ResponseVersion + 1
+	if err := dec.Decode(&got); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+}
@@ -0,0 +1,138 @@
+package define
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key, is a canonical identifier of a package level object that may be used
+// to compare, cache and later resolve objects without a cursor.  Its string
+// form is the import path followed by the dot separated parent type and name,
+// for example:
+//
+//	net/http                 package
+//	net/http.Get             function, variable, constant or type
+//	net/http.Client.Do       method
+//	net/http.Client.Timeout  field
+//	pkg.Outer.Inner.Field    field of an anonymous struct
+//
+// Import paths with a dot in their last element are quoted, such as
+// "gopkg.in/yaml.v2".Node.
+type Key struct {
+	PkgPath string // import path
+	Parent  string // type declaring a method or field, if any
+	Name    string // name, dot separated for fields of anonymous structs
+}
+
+// Key, returns the canonical Key of o.  Objects declared within functions do
+// not have keys.
+func (o *Object) Key() (Key, error) {
+	if o.PkgPath == "" {
+		return Key{}, fmt.Errorf("no package for object: %s", o.Name)
+	}
+	switch o.ObjType {
+	case Package:
+		return Key{PkgPath: o.PkgPath}, nil
+	case Const, Var, TypeName, Func, Method, Interface:
+	default:
+		return Key{}, fmt.Errorf("no key for %s: %s", o.ObjType, o.Name)
+	}
+	if o.local {
+		return Key{}, fmt.Errorf("no key for local object: %s", o.Name)
+	}
+	k := Key{PkgPath: o.PkgPath, Name: o.Name}
+	if o.ObjType == Method || o.ObjType == Interface || o.IsField {
+		k.Parent = o.Parent
+	}
+	if o.IsField && len(o.Fields) != 0 {
+		k.Name = strings.Join(o.Fields, ".") + "." + o.Name
+	}
+	return k, nil
+}
+
+// IsValid, reports if k identifies a package or object.
+func (k Key) IsValid() bool {
+	return k.PkgPath != "" && (k.Parent == "" || k.Name != "")
+}
+
+func (k Key) String() string {
+	s := k.PkgPath
+	if n := strings.LastIndex(s, "/"); strings.Contains(s[n+1:], ".") {
+		s = strconv.Quote(s)
+	}
+	if k.Parent != "" {
+		s += "." + k.Parent
+	}
+	if k.Name != "" {
+		s += "." + k.Name
+	}
+	return s
+}
+
+// ParseKey, parses the string form of a Key.  The parent of a key naming a
+// method or field cannot be distinguished from the name of an anonymous struct
+// field, so for more than one name after the import path the first is the
+// parent and the rest the name.
+func ParseKey(s string) (Key, error) {
+	var k Key
+	var rest string
+	named := false // the import path is followed by a dot and names
+	if strings.HasPrefix(s, `"`) {
+		n := strings.Index(s[1:], `"`)
+		if n == -1 {
+			return Key{}, fmt.Errorf("invalid key: %s", s)
+		}
+		path, err := strconv.Unquote(s[:n+2])
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid key: %s", s)
+		}
+		k.PkgPath = path
+		if rest = s[n+2:]; rest != "" {
+			if rest[0] != '.' {
+				return Key{}, fmt.Errorf("invalid key: %s", s)
+			}
+			rest = rest[1:]
+			named = true
+		}
+	} else {
+		n := strings.LastIndex(s, "/") + 1
+		if i := strings.IndexByte(s[n:], '.'); i != -1 {
+			k.PkgPath = s[:n+i]
+			rest = s[n+i+1:]
+			named = true
+		} else {
+			k.PkgPath = s
+		}
+	}
+	if k.PkgPath == "" {
+		return Key{}, errors.New("invalid key: missing import path")
+	}
+	if !named {
+		return k, nil
+	}
+	// Empty names, such as in "net/http.", are not identifiers.
+	names := strings.Split(rest, ".")
+	for _, name := range names {
+		if !isIdentifier(name) {
+			return Key{}, fmt.Errorf("invalid key: %s", s)
+		}
+	}
+	if len(names) == 1 {
+		k.Name = names[0]
+	} else {
+		k.Parent = names[0]
+		k.Name = strings.Join(names[1:], ".")
+	}
+	return k, nil
+}
+
+// Key, returns the canonical Key of the object at the cursor.
+func (q *Query) Key() (Key, error) {
+	o, _, err := q.Object()
+	if err != nil {
+		return Key{}, err
+	}
+	return o.Key()
+}
@@ -0,0 +1,98 @@
+package define
+
+import "testing"
+
+func TestKeyRoundTrip(t *testing.T) {
+	tests := []struct {
+		s   string
+		key Key
+	}{
+		{"net/http", Key{PkgPath: "net/http"}},
+		{"net/http.Get", Key{PkgPath: "net/http", Name: "Get"}},
+		{"net/http.Client.Do", Key{PkgPath: "net/http", Parent: "Client", Name: "Do"}},
+		{"pkg.Outer.Inner.Field", Key{PkgPath: "pkg", Parent: "Outer", Name: "Inner.Field"}},
+		{`"gopkg.in/yaml.v2"`, Key{PkgPath: "gopkg.in/yaml.v2"}},
+		{`"gopkg.in/yaml.v2".Node`, Key{PkgPath: "gopkg.in/yaml.v2", Name: "Node"}},
+		{`"gopkg.in/yaml.v2".Node.Decode`, Key{PkgPath: "gopkg.in/yaml.v2", Parent: "Node", Name: "Decode"}},
+		{"github.com/a/b.T", Key{PkgPath: "github.com/a/b", Name: "T"}},
+	}
+	for _, test := range tests {
+		if s := test.key.String(); s != test.s {
+			t.Errorf("String(%+v) = %q, want %q", test.key, s, test.s)
+		}
+		k, err := ParseKey(test.s)
+		if err != nil {
+			t.Errorf("ParseKey(%q): %s", test.s, err)
+			continue
+		}
+		if k != test.key {
+			t.Errorf("ParseKey(%q) = %+v, want %+v", test.s, k, test.key)
+		}
+	}
+}
+
+func TestParseKeyInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		".Get",
+		"net/http.",
+		"net/http.Client.",
+		"net/http..Get",
+		"net/http.1",
+		`"gopkg.in/yaml.v2".`,
+		`"gopkg.in/yaml.v2"Node`,
+		`"gopkg.in/yaml.v2`,
+		`"".Node`,
+	} {
+		if k, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) = %+v, want error", s, k)
+		}
+	}
+}
+
+func TestObjectKey(t *testing.T) {
+	conf := testConfig(t)
+	tests := []struct {
+		sel string
+		key Key
+	}{
+		{"Area() int {", Key{PkgPath: "rename", Parent: "Square", Name: "Area"}},
+		{"Side int", Key{PkgPath: "rename", Parent: "Square", Name: "Side"}},
+		{"Count(1)", Key{PkgPath: "rename", Name: "Count"}},
+		{"fmt.Println", Key{PkgPath: "fmt"}},
+	}
+	for _, test := range tests {
+		path, src, off := testSource(t, "rename/rename.go", test.sel)
+		o, _, err := conf.Object(path, off, src)
+		if err != nil {
+			t.Fatalf("%q: %s", test.sel, err)
+		}
+		k, err := o.Key()
+		if err != nil {
+			t.Fatalf("%q: %s", test.sel, err)
+		}
+		if k != test.key {
+			t.Errorf("%q: got key %+v, want %+v", test.sel, k, test.key)
+		}
+		if test.key.PkgPath != "rename" {
+			continue
+		}
+		pos, _, err := conf.Lookup(k.String())
+		if err != nil {
+			t.Errorf("Lookup(%q): %s", k, err)
+			continue
+		}
+		if pos.Filename != o.Position.Filename || pos.Line != o.Position.Line {
+			t.Errorf("Lookup(%q) = %s:%d, want %s:%d", k, pos.Filename,
+				pos.Line, o.Position.Filename, o.Position.Line)
+		}
+	}
+	path, src, off := testSource(t, "rename/rename.go", "total := n")
+	o, _, err := conf.Object(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Key(); err == nil {
+		t.Errorf("expected error for the key of local object: %s", o.Name)
+	}
+}
@@ -0,0 +1,130 @@
+package define
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+)
+
+// generatedRx, matches the comment marking a file as generated, see
+// https://golang.org/s/generatedcode.
+var generatedRx = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated, reports if src has a generated code comment before the first
+// non-comment, non-blank line.
+func isGenerated(src []byte) bool {
+	for len(src) != 0 {
+		line := src
+		if n := bytes.IndexByte(src, '\n'); n != -1 {
+			line = src[:n]
+			src = src[n+1:]
+		} else {
+			src = nil
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		switch {
+		case generatedRx.Match(line):
+			return true
+		case len(bytes.TrimSpace(line)) == 0:
+		case bytes.HasPrefix(line, []byte("//")):
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// hasLineDirective, reports if src may contain a //line directive.
+func hasLineDirective(src []byte) bool {
+	return bytes.Contains(src, []byte("//line ")) ||
+		bytes.Contains(src, []byte("/*line "))
+}
+
+// adjustedPosition, returns position p in src with //line directives applied.
+// If src is not Go source or has no directives p is returned.
+func adjustedPosition(p token.Position, src []byte) token.Position {
+	if !hasSuffix(p.Filename, ".go") || !hasLineDirective(src) ||
+		p.Offset < 0 || p.Offset > len(src) {
+		return p
+	}
+	fset := token.NewFileSet()
+	af, _ := parser.ParseFile(fset, p.Filename, src, 0)
+	if af == nil {
+		return p
+	}
+	f := fset.File(af.Pos())
+	if f == nil || p.Offset > f.Size() {
+		return p
+	}
+	return f.PositionFor(f.Pos(p.Offset), true)
+}
+
+// setPosition, sets the positions of o from its unadjusted position p, src is
+// the source of the file containing p and is read if nil.
+func (c *Config) setPosition(o *Object, p token.Position, src []byte) {
+	if src == nil && p.Filename != "" {
+		src, _ = newOverlay(c.Overlay).readFile(p.Filename)
+	}
+	o.setPositions(p, adjustedPosition(p, src), isGenerated(src), c.Unadjusted)
+}
+
+// setPosition, is like Config.setPosition for the Config of c.
+func (c *searchContext) setPosition(o *Object, p token.Position, src []byte) {
+	if src == nil && p.Filename != "" {
+		src, _ = c.readFile(p.Filename)
+	}
+	o.setPositions(p, adjustedPosition(p, src), isGenerated(src), c.unadjusted)
+}
+
+// setFilePosition, sets the positions of o from pos in fset, which records the
+// //line directives of the parsed file af containing pos.
+func (c *searchContext) setFilePosition(o *Object, pos token.Pos, fset *token.FileSet, af *ast.File) {
+	raw := positionFor(pos, fset)
+	if raw == nil {
+		return
+	}
+	o.setPositions(*raw, fset.PositionFor(pos, true), isGeneratedFile(af), c.unadjusted)
+}
+
+func (o *Object) setPositions(raw, adjusted token.Position, generated, unadjusted bool) {
+	o.Unadjusted = Position(raw)
+	o.Adjusted = Position(adjusted)
+	o.Generated = generated
+	if unadjusted {
+		o.Position = o.Unadjusted
+	} else {
+		o.Position = o.Adjusted
+	}
+}
+
+// resultPosition, returns the position of pos in fset, applying //line
+// directives unless positions are unadjusted.
+func (c *searchContext) resultPosition(pos token.Pos, fset *token.FileSet) *token.Position {
+	p := positionFor(pos, fset)
+	if p != nil && !c.unadjusted {
+		adj := fset.PositionFor(pos, true)
+		p = &adj
+	}
+	return p
+}
+
+// isGeneratedFile, reports if af has a generated code comment before its
+// package clause.
+func isGeneratedFile(af *ast.File) bool {
+	if af == nil {
+		return false
+	}
+	for _, cg := range af.Comments {
+		if cg.Pos() >= af.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if generatedRx.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package define
+
+import (
+	"bytes"
+	"context"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// linkname, is a go:linkname directive.
+type linkname struct {
+	local  string // local name
+	remote string // import path qualified remote name, may be empty
+	off    int    // offset of the directive
+}
+
+var linknamePrefix = []byte("//go:linkname ")
+
+// parseLinknames, returns the go:linkname directives in src.
+func parseLinknames(src []byte) []linkname {
+	var links []linkname
+	off := 0
+	for len(src) != 0 {
+		line := src
+		if n := bytes.IndexByte(src, '\n'); n != -1 {
+			line = src[:n+1]
+		}
+		if bytes.HasPrefix(line, linknamePrefix) {
+			f := strings.Fields(string(line[len(linknamePrefix):]))
+			if len(f) != 0 {
+				l := linkname{local: f[0], off: off}
+				if len(f) > 1 {
+					l.remote = f[1]
+				}
+				links = append(links, l)
+			}
+		}
+		off += len(line)
+		src = src[len(line):]
+	}
+	return links
+}
+
+// splitLinkname, splits a go:linkname remote name into its import path and
+// symbol, for example "internal/poll.runtime_Semacquire".
+func splitLinkname(remote string) (path, sym string) {
+	n := strings.LastIndex(remote, "/") + 1
+	if i := strings.IndexByte(remote[n:], '.'); i != -1 {
+		return remote[:n+i], remote[n+i+1:]
+	}
+	return "", remote
+}
+
+// followLinkname, follows go:linkname directives for function o, declared at
+// pos without a body, to its implementation.  Both the directive of the
+// declaring package ("pull") and directives of the packages it imports and the
+// runtime ("push") are checked.  The positions of the body-less declaration
+// and the directive are returned as hops.  If linkname lookups are disabled or
+// no directive is found, pos and src are returned unchanged.
+func (c *searchContext) followLinkname(ctx context.Context, o *Object, pos *token.Position, src []byte) (*token.Position, []byte, []Position) {
+	if !c.linkname || !c.bodylessFunc(o, pos, src) {
+		return pos, src, nil
+	}
+	dir := filepath.Dir(pos.Filename)
+	if p, s, d := c.pullLinkname(ctx, dir, o.Name); p != nil {
+		return p, s, []Position{Position(*pos), Position(*d)}
+	}
+	remote := c.importPath(dir) + "." + o.Name
+	for _, path := range c.linknameImports(dir) {
+		if p, s, d := c.pushLinkname(path, remote); p != nil {
+			return p, s, []Position{Position(*pos), Position(*d)}
+		}
+	}
+	return pos, src, nil
+}
+
+// pullLinkname, returns the position of the implementation named by a
+// go:linkname directive for local function name in the package in dir, and the
+// position of the directive.
+func (c *searchContext) pullLinkname(ctx context.Context, dir, name string) (pos *token.Position, src []byte, directive *token.Position) {
+	names, err := c.pkgFiles(dir, false)
+	if err != nil {
+		return nil, nil, nil
+	}
+	for _, path := range names {
+		b, err := c.readFile(path)
+		if err != nil || !bytes.Contains(b, linknamePrefix) {
+			continue
+		}
+		for _, l := range parseLinknames(b) {
+			if l.local != name || l.remote == "" {
+				continue
+			}
+			pkgPath, sym := splitLinkname(l.remote)
+			if pkgPath == "" || strings.Contains(sym, ".") {
+				continue // methods are not supported
+			}
+			p, s, err := c.objectPosition(ctx, pkgPath, declFinder{Name: sym})
+			if err == nil && p != nil {
+				return p, s, offsetPosition(path, b, l.off)
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// pushLinkname, returns the position of the function in package pkgPath with a
+// go:linkname directive naming remote, and the position of the directive.
+func (c *searchContext) pushLinkname(pkgPath, remote string) (pos *token.Position, src []byte, directive *token.Position) {
+	dir, err := c.pkgPath(pkgPath)
+	if err != nil {
+		return nil, nil, nil
+	}
+	names, err := c.pkgFiles(dir, false)
+	if err != nil {
+		return nil, nil, nil
+	}
+	for _, path := range names {
+		b, err := c.readFile(path)
+		if err != nil || !bytes.Contains(b, []byte(remote)) {
+			continue
+		}
+		for _, l := range parseLinknames(b) {
+			if l.remote != remote {
+				continue
+			}
+			fset := token.NewFileSet()
+			af, _ := parser.ParseFile(fset, path, b, 0)
+			if p := (declFinder{Name: l.local}).Find(af, fset); p != nil {
+				return p, b, offsetPosition(path, b, l.off)
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// linknameImports, returns the import paths of the packages that may push an
+// implementation to the package in dir: its imports and the runtime.
+func (c *searchContext) linknameImports(dir string) []string {
+	paths := []string{"runtime"}
+	if pkg, err := c.bctx.ImportDir(dir, 0); err == nil {
+		for _, path := range pkg.Imports {
+			if path != "runtime" && path != "unsafe" && path != "C" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
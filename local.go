@@ -0,0 +1,250 @@
+package define
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/types"
+)
+
+// localObject, resolves nodes that can be found without type information,
+// such as labels, branch statements and function keywords.  If node cannot be
+// resolved locally nil is returned.
+func localObject(af *ast.File, fset *token.FileSet, node ast.Node) (*Object, error) {
+	switch n := node.(type) {
+	case *ast.ReturnStmt, *ast.DeferStmt:
+		// Deferred calls run when the enclosing function returns.
+		fn := enclosingFunc(enclosingPath(af, n))
+		if fn == nil {
+			return nil, fmt.Errorf("%T not in function", n)
+		}
+		return newLocalObject(af, fset, funcName(fn), Signature, fn)
+	case *ast.GoStmt:
+		// Function literals are resolved here, everything else is
+		// resolved using type information.
+		if lit, ok := unparen(n.Call.Fun).(*ast.FuncLit); ok {
+			return newLocalObject(af, fset, funcName(lit), Signature, lit)
+		}
+	case *ast.FuncType:
+		path := enclosingPath(af, n)
+		if fn := enclosingFunc(path); fn != nil && len(path) != 0 && path[0] == fn {
+			return newLocalObject(af, fset, funcName(fn), Signature, fn)
+		}
+		return newLocalObject(af, fset, "func", Signature, n)
+	case *ast.BranchStmt:
+		target, err := branchTarget(af, n)
+		if err != nil {
+			return nil, err
+		}
+		name := n.Tok.String()
+		if n.Label != nil {
+			name = n.Label.Name
+		}
+		return newLocalObject(af, fset, name, Label, target)
+	case *ast.Ident:
+		path := enclosingPath(af, n)
+		if len(path) != 0 {
+			if ls, ok := path[0].(*ast.LabeledStmt); ok && ls.Label == n {
+				return newLocalObject(af, fset, n.Name, Label, ls)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func newLocalObject(af *ast.File, fset *token.FileSet, name string, typ Type, target ast.Node) (*Object, error) {
+	pos := target.Pos()
+	if ls, ok := target.(*ast.LabeledStmt); ok {
+		pos = ls.Label.Pos()
+	}
+	p := positionFor(pos, fset)
+	if p == nil {
+		return nil, fmt.Errorf("invalid position for: %s", name)
+	}
+	o := &Object{
+		Name:     name,
+		PkgName:  af.Name.Name,
+		ObjType:  typ,
+		Position: Position(*p),
+		pos:      pos,
+	}
+	return o, nil
+}
+
+// namedResult, returns the enclosing function signature if obj, the object
+// of node, is a named result of the function.
+func namedResult(af *ast.File, fset *token.FileSet, node ast.Node, obj types.Object, info *types.Info) *Object {
+	v, ok := obj.(*types.Var)
+	if !ok || v.IsField() {
+		return nil
+	}
+	for _, n := range enclosingPath(af, node) {
+		var ft *ast.FuncType
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			ft = fn.Type
+		case *ast.FuncLit:
+			ft = fn.Type
+		default:
+			continue
+		}
+		if ft.Results == nil {
+			continue
+		}
+		for _, field := range ft.Results.List {
+			for _, id := range field.Names {
+				if info.Defs[id] == v {
+					o, _ := newLocalObject(af, fset, funcName(n), Signature, n)
+					return o
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// enclosingFunc, returns the innermost *ast.FuncDecl or *ast.FuncLit of path.
+func enclosingFunc(path []ast.Node) ast.Node {
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return n
+		}
+	}
+	return nil
+}
+
+// funcName, returns the name of function fn or "func" for function literals.
+func funcName(fn ast.Node) string {
+	if d, ok := fn.(*ast.FuncDecl); ok {
+		return d.Name.Name
+	}
+	return "func"
+}
+
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = p.X
+	}
+}
+
+// branchTarget, returns the statement that branch statement n transfers
+// control to.  For labeled branches this is the *ast.LabeledStmt, otherwise
+// the enclosing for, range, switch or select statement, or for fallthrough
+// the next case clause.
+func branchTarget(af *ast.File, n *ast.BranchStmt) (ast.Node, error) {
+	path := enclosingPath(af, n)
+	if n.Label != nil {
+		// Labels are scoped to the enclosing function.
+		for _, node := range path {
+			var body *ast.BlockStmt
+			switch fn := node.(type) {
+			case *ast.FuncDecl:
+				body = fn.Body
+			case *ast.FuncLit:
+				body = fn.Body
+			default:
+				continue
+			}
+			if ls := findLabel(body, n.Label.Name); ls != nil {
+				return ls, nil
+			}
+			break
+		}
+		return nil, fmt.Errorf("label not found: %s", n.Label.Name)
+	}
+	for i, node := range path {
+		switch s := node.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			if n.Tok == token.BREAK || n.Tok == token.CONTINUE {
+				return s, nil
+			}
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			if n.Tok == token.BREAK {
+				return s, nil
+			}
+		case *ast.CaseClause:
+			if n.Tok == token.FALLTHROUGH && i+1 < len(path) {
+				if next := nextClause(path[i+1], s); next != nil {
+					return next, nil
+				}
+			}
+		case *ast.FuncDecl, *ast.FuncLit:
+			return nil, fmt.Errorf("no target for %s statement", n.Tok)
+		}
+	}
+	return nil, errors.New("branch statement not in function")
+}
+
+// nextClause, returns the case clause following clause cc in block.
+func nextClause(block ast.Node, cc *ast.CaseClause) *ast.CaseClause {
+	b, ok := block.(*ast.BlockStmt)
+	if !ok {
+		return nil
+	}
+	for i, s := range b.List {
+		if s == cc && i+1 < len(b.List) {
+			next, _ := b.List[i+1].(*ast.CaseClause)
+			return next
+		}
+	}
+	return nil
+}
+
+// findLabel, returns the labeled statement with name in body, ignoring
+// function literals which have their own label scope.
+func findLabel(body *ast.BlockStmt, name string) *ast.LabeledStmt {
+	if body == nil {
+		return nil
+	}
+	var ls *ast.LabeledStmt
+	ast.Inspect(body, func(node ast.Node) bool {
+		if ls != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.LabeledStmt:
+			if n.Label.Name == name {
+				ls = n
+				return false
+			}
+		}
+		return true
+	})
+	return ls
+}
+
+// enclosingPath, returns the nodes enclosing node n in af, innermost first.
+func enclosingPath(af *ast.File, n ast.Node) []ast.Node {
+	var stack, path []ast.Node
+	ast.Inspect(af, func(node ast.Node) bool {
+		if path != nil {
+			return false
+		}
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if node == n {
+			path = make([]ast.Node, len(stack))
+			for i := range stack {
+				path[i] = stack[len(stack)-1-i]
+			}
+			return false
+		}
+		if n.Pos() < node.Pos() || node.End() < n.End() {
+			return false
+		}
+		stack = append(stack, node)
+		return true
+	})
+	return path
+}
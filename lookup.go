@@ -0,0 +1,81 @@
+package define
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+func (c *Config) Lookup(name string) (*Position, []byte, error) {
+	return c.LookupContext(context.Background(), name)
+}
+
+// LookupContext, returns the position and source of the declaration of the
+// object with qualified name, the string form of a Key, such as
+// "net/http.Client.Do".  Searching stops when ctx is done.
+func (c *Config) LookupContext(ctx context.Context, name string) (*Position, []byte, error) {
+	k, err := ParseKey(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc := newSearchContext(c)
+	var (
+		pos *token.Position
+		src []byte
+	)
+	if k.Name == "" {
+		pos, src, _, err = sc.findPkgDoc(ctx, k.PkgPath)
+	} else {
+		pos, src, err = sc.objectPosition(ctx, k.PkgPath, k.finder())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var o Object
+	c.setPosition(&o, *pos, src)
+	return &o.Position, src, nil
+}
+
+// finder, returns the PosFinder for the object identified by k.  Keys do not
+// record if a member of a type is a method or field, so both are searched.
+func (k Key) finder() PosFinder {
+	if k.Parent == "" {
+		return declFinder{Name: k.Name}
+	}
+	names := strings.Split(k.Name, ".")
+	field := fieldFinder{
+		Name:   names[len(names)-1],
+		Parent: k.Parent,
+		Fields: names[:len(names)-1],
+	}
+	if len(names) > 1 {
+		return field
+	}
+	return anyFinder{
+		methodFinder{Name: k.Name, TypeName: k.Parent},
+		ifaceMethodFinder{Name: k.Name, TypeName: k.Parent},
+		field,
+	}
+}
+
+// anyFinder, returns the first match of its PosFinders.
+type anyFinder []PosFinder
+
+func (f anyFinder) Candidate(b []byte) bool {
+	for _, pf := range f {
+		if pf.Candidate(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f anyFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
+	for _, pf := range f {
+		if p := pf.Find(af, fset); p != nil {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package define
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupRank(t *testing.T) {
+	conf := testConfig(t)
+	tests := []struct {
+		name string
+		file string // empty if not found
+	}{
+		{"rank.Rank", "rank.go"},
+		{"rank.Internal", "internal_test.go"},
+		{"rank.Helper", ""},
+	}
+	for _, test := range tests {
+		pos, _, err := conf.Lookup(test.name)
+		if test.file == "" {
+			if err == nil {
+				t.Errorf("%s: expected error, got: %s", test.name, pos.Filename)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if base := filepath.Base(pos.Filename); base != test.file {
+			t.Errorf("%s: got file %s, want %s", test.name, base, test.file)
+		}
+	}
+}
+
+func TestObjectFileRank(t *testing.T) {
+	conf := testConfig(t)
+	tests := []struct {
+		sel   string
+		index int
+	}{
+		{"Probe", 0},  // declaration in the queried file
+		{"Rank()", 1}, // found by the type checker in another file
+	}
+	for _, test := range tests {
+		path, src, off := testSource(t, "rank/probe.go", test.sel)
+		o, _, err := conf.Object(path, off, src)
+		if err != nil {
+			t.Fatalf("%q: %s", test.sel, err)
+		}
+		if o.FileRank == nil {
+			t.Errorf("%q: no file rank", test.sel)
+			continue
+		}
+		if o.FileRank.Index != test.index {
+			t.Errorf("%q: got rank %d, want %d", test.sel, o.FileRank.Index, test.index)
+		}
+	}
+}
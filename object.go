@@ -19,6 +19,8 @@ const (
 	Method
 	Interface
 	Package
+	Label
+	Signature
 )
 
 var typeNames = [...]string{
@@ -31,6 +33,8 @@ var typeNames = [...]string{
 	"Method",
 	"Interface",
 	"Package",
+	"Label",
+	"Signature",
 }
 
 func (t Type) String() string {
@@ -40,12 +44,37 @@ func (t Type) String() string {
 	return typeNames[Invalid]
 }
 
+// SelectionKind, is the kind of selector expression an object was selected
+// by.
+type SelectionKind int
+
+const (
+	NoSelection      SelectionKind = iota // not selected
+	FieldValue                            // x.f is a struct field
+	MethodValue                           // x.m is a method bound to x
+	MethodExpression                      // T.m is a method expression
+)
+
+var selectionNames = [...]string{
+	"NoSelection",
+	"FieldValue",
+	"MethodValue",
+	"MethodExpression",
+}
+
+func (k SelectionKind) String() string {
+	if 0 <= k && int(k) < len(selectionNames) {
+		return selectionNames[k]
+	}
+	return selectionNames[NoSelection]
+}
+
 // Same as token.Position
 type Position struct {
-	Filename string // filename, if any
-	Offset   int    // offset, starting at 0
-	Line     int    // line number, starting at 1
-	Column   int    // column number, starting at 1 (character count)
+	Filename string `json:"filename,omitempty"` // filename, if any
+	Offset   int    `json:"offset"`             // offset, starting at 0
+	Line     int    `json:"line"`               // line number, starting at 1
+	Column   int    `json:"column"`             // column number, starting at 1 (character count)
 }
 
 func newPosition(tp token.Position) *Position {
@@ -70,14 +99,36 @@ func (p Position) String() string {
 }
 
 type Object struct {
-	Name     string
-	Parent   string // parent type
-	PkgName  string
-	PkgPath  string
-	ObjType  Type // only relevanty when finding imported types
-	Position Position
-	IsField  bool // only relevant when finding imported types
-	pos      token.Pos
+	Name      string        `json:"name"`
+	Parent    string        `json:"parent,omitempty"` // parent type
+	PkgName   string        `json:"pkgName,omitempty"`
+	PkgPath   string        `json:"pkgPath,omitempty"` // import path
+	PkgDir    string        `json:"pkgDir,omitempty"`  // package directory, only set for packages
+	ObjType   Type          `json:"objType"`           // only relevanty when finding imported types
+	Position  Position      `json:"position"`
+	IsField   bool          `json:"isField,omitempty"`   // only relevant when finding imported types
+	Selection SelectionKind `json:"selection,omitempty"` // kind of selector expression, if any
+	Fields    []string      `json:"fields,omitempty"`    // anonymous struct fields enclosing a field, from Parent
+	Hops      []Position    `json:"hops,omitempty"`      // declarations followed to reach Position, if any
+	FileRank  *FileRank     `json:"fileRank,omitempty"`  // rank of the file the definition is in, nil if unranked
+
+	Adjusted   Position `json:"adjusted"`            // position with //line directives applied
+	Unadjusted Position `json:"unadjusted"`          // position in the file containing the definition
+	Generated  bool     `json:"generated,omitempty"` // defined in a generated file
+
+	pos   token.Pos
+	local bool // declared in a function
+}
+
+// FileRank, is the rank of a file among the files of a package searched for a
+// definition.  Files matching the build context rank first, then non-test
+// files, then files are ordered by name; the first match is returned.  Test
+// and excluded files are only searched if no other file matches, and only if
+// they declare the same package.
+type FileRank struct {
+	Index    int  `json:"index"`              // index of the file in rank order
+	Excluded bool `json:"excluded,omitempty"` // the file is excluded by the build context
+	Test     bool `json:"test,omitempty"`     // the file is a test file
 }
 
 func (o *Object) setPkg(p *types.Package) {
@@ -94,10 +145,16 @@ func (o *Object) setParent(obj types.Object) {
 	}
 }
 
-func (o *Object) Finder() (f posFinder, err error) {
+func (o *Object) Finder() (f PosFinder, err error) {
 	switch o.ObjType {
-	case Const, TypeName, Func, Interface:
+	case Const, TypeName, Func:
 		f = declFinder{Name: o.Name}
+	case Interface:
+		if o.Parent != "" {
+			f = ifaceMethodFinder{Name: o.Name, TypeName: o.Parent}
+		} else {
+			f = declFinder{Name: o.Name}
+		}
 	case Var:
 		if o.IsField {
 			if o.Parent == "" {
@@ -106,6 +163,7 @@ func (o *Object) Finder() (f posFinder, err error) {
 			f = fieldFinder{
 				Name:   o.Name,
 				Parent: o.Parent,
+				Fields: o.Fields,
 			}
 		} else {
 			f = declFinder{Name: o.Name}
@@ -129,6 +187,37 @@ func newSelector(sel *types.Selection) (*Object, error) {
 		pos:  sel.Obj().Pos(),
 	}
 	o.setPkg(sel.Obj().Pkg())
+	switch sel.Kind() {
+	case types.FieldVal:
+		o.Selection = FieldValue
+	case types.MethodVal:
+		o.Selection = MethodValue
+	case types.MethodExpr:
+		o.Selection = MethodExpression
+	}
+	switch obj := sel.Obj().(type) {
+	case *types.Var:
+		// Promoted fields and fields of anonymous structs are declared
+		// by a type other than the receiver.
+		if t, path := fieldParent(obj.Pkg(), obj); t != nil {
+			o.setParent(t.Obj())
+			o.Fields = path
+			o.IsField = true
+			o.ObjType = Var
+			return o, nil
+		}
+	case *types.Func:
+		// Promoted methods and methods selected through a pointer are
+		// declared by the receiver of the method, not the selection.
+		if t, iface := methodParent(obj); t != nil {
+			o.setParent(t.Obj())
+			o.ObjType = Method
+			if iface {
+				o.ObjType = Interface
+			}
+			return o, nil
+		}
+	}
 	switch t := derefType(sel.Recv()).(type) {
 	case *types.Named:
 		o.setParent(t.Obj())
@@ -137,19 +226,30 @@ func newSelector(sel *types.Selection) (*Object, error) {
 		// Locally declared type, maybe an anonymous struct.
 		return nil, fmt.Errorf("unexpected Recv type: %#v for object: %#v", t, sel.Obj())
 	}
-	switch sel.Kind() {
-	case types.FieldVal:
+	if sel.Kind() == types.FieldVal {
 		o.IsField = true
 		o.ObjType = Var
-	case types.MethodVal:
-		o.ObjType = Method
-	case types.MethodExpr:
-		// TODO: Fix
+	} else {
 		o.ObjType = Method
 	}
 	return o, nil
 }
 
+// methodParent, returns the named type declaring method fn and reports if it
+// is an interface.
+func methodParent(fn *types.Func) (*types.Named, bool) {
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return nil, false
+	}
+	t, ok := derefType(sig.Recv().Type()).(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	_, iface := t.Underlying().(*types.Interface)
+	return t, iface
+}
+
 func newObject(obj types.Object, sel *types.Selection) (*Object, error) {
 	// WARN: Dev only
 	if sel != nil {
@@ -173,6 +273,13 @@ func newObject(obj types.Object, sel *types.Selection) (*Object, error) {
 	case *types.Var:
 		o.ObjType = Var
 		o.IsField = typ.IsField()
+		if o.IsField {
+			if t, path := fieldParent(typ.Pkg(), typ); t != nil {
+				o.setParent(t.Obj())
+				o.Fields = path
+				break
+			}
+		}
 		if t, ok := derefType(typ.Type()).(*types.Named); ok {
 			o.ObjType = TypeName
 			// WARN: This looks wrong
@@ -183,27 +290,44 @@ func newObject(obj types.Object, sel *types.Selection) (*Object, error) {
 				o.pos = obj.Pos() // WARN
 			}
 		}
+	case *types.Label:
+		o.ObjType = Label
 	case *types.Func:
-		if sig := typ.Type().(*types.Signature); sig != nil {
+		if sig := typ.Type().(*types.Signature); sig.Recv() == nil {
 			o.ObjType = Func
-		} else {
-			switch r := derefType(sig.Recv().Type()).(type) {
-			case *types.Named:
-				o.ObjType = Method
-				o.setParent(r.Obj())
-			case *types.Interface:
+		} else if t, iface := methodParent(typ); t != nil {
+			o.ObjType = Method
+			if iface {
 				o.ObjType = Interface
-			default:
-				// This should never happen
 			}
+			o.setParent(t.Obj())
+		} else if _, ok := derefType(sig.Recv().Type()).(*types.Interface); ok {
+			o.ObjType = Interface
 		}
 	default:
 		// TODO: log type
 		o.ObjType = Bad
 	}
+	if o.pos == obj.Pos() && isLocal(obj) {
+		o.local = true
+	}
 	return o, nil
 }
 
+// isLocal, reports if obj is a variable, constant, type or function declared
+// within a function.
+func isLocal(obj types.Object) bool {
+	switch obj.(type) {
+	case *types.Var, *types.Const, *types.TypeName, *types.Func:
+		if v, ok := obj.(*types.Var); ok && v.IsField() {
+			return false
+		}
+		pkg := obj.Pkg()
+		return pkg != nil && obj.Parent() != nil && obj.Parent() != pkg.Scope()
+	}
+	return false
+}
+
 func derefType(t types.Type) types.Type {
 	if p, ok := t.(*types.Pointer); ok {
 		return p.Elem()
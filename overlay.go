@@ -0,0 +1,77 @@
+package define
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// overlay, maps absolute filenames to the contents of unsaved files.
+type overlay map[string][]byte
+
+func newOverlay(m map[string][]byte) overlay {
+	if len(m) == 0 {
+		return nil
+	}
+	o := make(overlay, len(m))
+	for name, src := range m {
+		o[absPath(name)] = src
+	}
+	return o
+}
+
+// file, returns the overlay contents of file name, if any.
+func (o overlay) file(name string) ([]byte, bool) {
+	if len(o) == 0 {
+		return nil, false
+	}
+	src, ok := o[absPath(name)]
+	return src, ok
+}
+
+// dirFiles, returns the base names of the overlay files in directory dir.
+func (o overlay) dirFiles(dir string) []string {
+	if len(o) == 0 {
+		return nil
+	}
+	dir = absPath(dir)
+	var names []string
+	for name := range o {
+		if filepath.Dir(name) == dir {
+			names = append(names, filepath.Base(name))
+		}
+	}
+	return names
+}
+
+// readFile, returns the overlay contents of file name, if any, otherwise the
+// file is read from disk.
+func (o overlay) readFile(name string) ([]byte, error) {
+	if src, ok := o.file(name); ok {
+		return src, nil
+	}
+	return ioutil.ReadFile(name)
+}
+
+// openFile, returns a build.Context OpenFile function that opens overlay
+// files before falling back to fn, or os.Open if fn is nil.
+func (o overlay) openFile(fn func(string) (io.ReadCloser, error)) func(string) (io.ReadCloser, error) {
+	return func(name string) (io.ReadCloser, error) {
+		if src, ok := o.file(name); ok {
+			return ioutil.NopCloser(bytes.NewReader(src)), nil
+		}
+		if fn != nil {
+			return fn(name)
+		}
+		return os.Open(name)
+	}
+}
+
+func absPath(name string) string {
+	if s, err := filepath.Abs(name); err == nil {
+		return s
+	}
+	return filepath.Clean(name)
+}
@@ -1,6 +1,7 @@
 package define
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go/ast"
@@ -11,19 +12,27 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"sync"
 
 	"golang.org/x/tools/go/types"
 
 	_ "golang.org/x/tools/go/gcimporter"
 )
 
-type context struct {
-	filename string
-	dirname  string
-	incTest  bool // include test files
-	src      []byte
-	ctx      *build.Context
+type searchContext struct {
+	filename   string
+	dirname    string
+	incTest    bool // include test files
+	src        []byte
+	bctx       *build.Context
+	pool       *workPool
+	maxSize    int64   // maximum file size, if zero there is no limit
+	overlay    overlay // unsaved file contents
+	asm        bool    // follow functions to their assembly implementation
+	linkname   bool    // follow go:linkname directives
+	finders    map[Type]FinderFunc
+	unadjusted bool // report positions ignoring //line directives
+
+	importPaths map[string]string // directory to import path cache
 
 	info    *types.Info
 	infoErr error
@@ -33,107 +42,400 @@ type context struct {
 	fset  *token.FileSet
 }
 
-func newContext(filename string, af *ast.File, fset *token.FileSet, ctx *build.Context) *context {
-	if ctx == nil {
-		ctx = &build.Default
-	}
+func newContext(ctx context.Context, filename string, src []byte, af *ast.File, fset *token.FileSet, conf *Config) *searchContext {
+	c := newSearchContext(conf)
 	name := filepath.Clean(filename)
-	c := context{
-		filename: name,
-		dirname:  filepath.Dir(name),
-		incTest:  hasSuffix(name, "_test.go"),
-		ctx:      ctx,
-		af:       af,
-		fset:     fset,
-		files:    []*ast.File{af},
-	}
-	c.parseTargetDir()
+	c.filename = name
+	c.dirname = filepath.Dir(name)
+	c.incTest = hasSuffix(name, "_test.go")
+	c.src = src
+	c.af = af
+	c.fset = fset
+	c.files = []*ast.File{af}
+	c.parseTargetDir(ctx)
+	return c
+}
+
+// newSearchContext, returns a searchContext for conf that is not associated
+// with a queried file.
+func newSearchContext(conf *Config) *searchContext {
+	if conf == nil {
+		conf = &DefaultConfig
+	}
+	c := searchContext{
+		bctx:       &conf.Context,
+		pool:       newWorkPool(conf.MaxWorkers),
+		maxSize:    conf.MaxFileSize,
+		overlay:    newOverlay(conf.Overlay),
+		asm:        conf.Assembly,
+		linkname:   conf.Linkname,
+		finders:    conf.Finders,
+		unadjusted: conf.Unadjusted,
+	}
+	if c.overlay != nil {
+		// Use overlay contents when matching build constraints.
+		bctx := conf.Context
+		bctx.OpenFile = c.overlay.openFile(conf.Context.OpenFile)
+		c.bctx = &bctx
+	}
 	return &c
 }
 
 type findRes struct {
-	pos *token.Position
-	src []byte
-	err error
+	pos  *token.Position
+	src  []byte
+	err  error
+	rank FileRank
+}
+
+func (c *searchContext) objectPosition(ctx context.Context, pkgpath string, f PosFinder) (*token.Position, []byte, error) {
+	res, err := c.searchPackage(ctx, pkgpath, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.pos, res.src, nil
 }
 
-func (c *context) objectPosition(pkgpath string, f posFinder) (*token.Position, []byte, error) {
+// searchPackage, searches the files of the package with import path pkgpath
+// using PosFinder f and returns the match in the highest ranked file.
+func (c *searchContext) searchPackage(ctx context.Context, pkgpath string, f PosFinder) (*findRes, error) {
 	if f == nil {
 		// should not happen
-		return nil, nil, errors.New("define: nil finder")
+		return nil, errors.New("define: nil finder")
 	}
-	path, err := c.pkgPath(pkgpath)
-	if err != nil {
-		return nil, nil, err
+	// The queried package is type checked using its directory as its path.
+	path := pkgpath
+	if pkgpath == "" || pkgpath != c.dirname {
+		var err error
+		if path, err = c.pkgPath(pkgpath); err != nil {
+			return nil, err
+		}
 	}
-	names, err := pkgFiles(&build.Default, path, false)
+	names, ranks, err := c.rankedFiles(path)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	chs := make([]chan *findRes, 0, len(names))
-	for _, name := range names {
-		chs = append(chs, searchAstFile(name, f))
+	// Test files and files excluded by the build context are only searched
+	// once the files of the package fail, skipping those declaring another
+	// package such as an external test package.
+	n := 0
+	for n < len(ranks) && !ranks[n].Excluded && !ranks[n].Test {
+		n++
 	}
+	p, first := c.searchFiles(ctx, names[:n], ranks[:n], f, nil)
+	if p == nil && ctx.Err() == nil && n < len(names) {
+		var want string
+		if n != 0 {
+			want = c.packageName(names[0])
+		}
+		var err error
+		p, err = c.searchFiles(ctx, names[n:], ranks[n:], f, func(src []byte) bool {
+			return samePackage(packageName(src), want)
+		})
+		if first == nil {
+			first = err
+		}
+	}
+	if p != nil {
+		return p, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if first == nil {
+		// WARN: Dev only
+		first = fmt.Errorf("pkgpath: %s finder: %#v", pkgpath, f)
+	}
+	return nil, first
+}
+
+// searchFiles, searches files names using PosFinder f and returns the match
+// in the first file, if any, and the first error.  If match is not nil files
+// it rejects are skipped.
+func (c *searchContext) searchFiles(ctx context.Context, names []string, ranks []FileRank, f PosFinder, match func([]byte) bool) (*findRes, error) {
+	// Cancel any outstanding searches once a result is found.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chs := make([]chan *findRes, len(names))
+	for i := range chs {
+		// Buffered so that searches never block, even if the result
+		// is never received.
+		chs[i] = make(chan *findRes, 1)
+	}
+	go c.pool.each(ctx, len(names), func(i int) {
+		chs[i] <- c.searchAstFile(ctx, names[i], f, match)
+	})
 	var first error
-	for _, ch := range chs {
-		switch p := <-ch; {
+	for i, ch := range chs {
+		var p *findRes
+		select {
+		case p = <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		switch {
 		case p == nil:
 			// should not happen
 			first = errors.New("define: nil response on find chan")
 		case p.pos != nil:
 			// Exit: success
-			return p.pos, p.src, nil
+			p.rank = ranks[i]
+			return p, nil
 		case p.err != nil && first == nil:
-			first = err
+			first = p.err
 		}
 	}
-	if first == nil {
-		// WARN: Dev only
-		first = fmt.Errorf("pkgpath: %s finder: %#v", pkgpath, f)
+	return nil, first
+}
+
+// packageName, returns the package name of the file at path, or an empty
+// string if it cannot be read.
+func (c *searchContext) packageName(path string) string {
+	b, err := c.readFile(path)
+	if err != nil {
+		return ""
 	}
-	return nil, nil, first
+	return packageName(b)
 }
 
-func searchAstFile(path string, f posFinder) chan *findRes {
-	ch := make(chan *findRes)
-	go func() {
-		b, err := ioutil.ReadFile(path)
-		if b != nil && f.Candidate(b) {
-			af, fset, err := parseFile(path, b)
-			pos := f.Find(af, fset)
-			ch <- &findRes{pos: pos, src: b, err: err}
-		} else {
-			// Send read err if b is nil.
-			ch <- &findRes{err: err}
+// packageName, returns the name in the package clause of src.
+func packageName(src []byte) string {
+	af, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly)
+	if err != nil || af.Name == nil {
+		return ""
+	}
+	return af.Name.Name
+}
+
+// samePackage, reports if a file declaring package name belongs to the
+// package named want.  If want is not known only external test packages are
+// rejected.
+func samePackage(name, want string) bool {
+	if want == "" {
+		return !hasSuffix(name, "_test")
+	}
+	return name == want
+}
+
+// rankedFiles, returns the Go files in dir, including test files and files
+// excluded by the build context, in rank order: files matching the build
+// context first, then non-test files, then by name.
+func (c *searchContext) rankedFiles(dir string) ([]string, []FileRank, error) {
+	f, err := os.Open(dir)
+	if err != nil && (!os.IsNotExist(err) || c.overlay == nil) {
+		return nil, nil, err
+	}
+	var names []string
+	if f != nil {
+		names, err = f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
 		}
-	}()
-	return ch
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, name := range c.overlay.dirFiles(dir) {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	var files []rankedFile
+	for _, name := range names {
+		if !isGoSource(name, true) {
+			continue
+		}
+		ok, _ := c.bctx.MatchFile(dir, name)
+		files = append(files, rankedFile{
+			name: filepath.Join(dir, name),
+			rank: FileRank{Excluded: !ok, Test: hasSuffix(name, "_test.go")},
+		})
+	}
+	sort.Sort(byRank(files))
+	paths := make([]string, len(files))
+	ranks := make([]FileRank, len(files))
+	for i, f := range files {
+		paths[i] = f.name
+		ranks[i] = f.rank
+		ranks[i].Index = i
+	}
+	return paths, ranks, nil
 }
 
-func (c *context) findPkgDoc(pkgPath, pkgName string) (*token.Position, error) {
-	path, err := c.pkgPath(pkgPath)
+// fileRank, returns the rank of filename among the files of its package, or
+// nil if it is not a Go file of the package.
+func (c *searchContext) fileRank(filename string) *FileRank {
+	filename = filepath.Clean(filename)
+	names, ranks, err := c.rankedFiles(filepath.Dir(filename))
 	if err != nil {
-		return nil, err
+		return nil
+	}
+	for i, name := range names {
+		if name == filename {
+			return &ranks[i]
+		}
+	}
+	return nil
+}
+
+type rankedFile struct {
+	name string
+	rank FileRank
+}
+
+type byRank []rankedFile
+
+func (r byRank) Len() int      { return len(r) }
+func (r byRank) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byRank) Less(i, j int) bool {
+	a, b := r[i].rank, r[j].rank
+	if a.Excluded != b.Excluded {
+		return !a.Excluded
+	}
+	if a.Test != b.Test {
+		return !a.Test
 	}
-	names, err := pkgFiles(c.ctx, path, false)
+	return r[i].name < r[j].name
+}
+
+// searchAstFile, searches the file at path using PosFinder f, skipping it if
+// match is not nil and rejects its source.
+func (c *searchContext) searchAstFile(ctx context.Context, path string, f PosFinder, match func([]byte) bool) *findRes {
+	b, err := c.readFile(path)
+	if b != nil && match != nil && !match(b) {
+		return &findRes{}
+	}
+	if b != nil && ctx.Err() == nil && f.Candidate(b) {
+		af, fset, err := parseFile(path, b)
+		pos := f.Find(af, fset)
+		return &findRes{pos: pos, src: b, err: err}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	// Return read err if b is nil.
+	return &findRes{err: err}
+}
+
+// readFile, reads the file at path, preferring the source of the queried file
+// and overlay contents, returning an error if the file is larger than the
+// maximum file size.
+func (c *searchContext) readFile(path string) ([]byte, error) {
+	if path == c.filename && c.src != nil {
+		return c.src, nil
+	}
+	if src, ok := c.overlay.file(path); ok {
+		return src, nil
+	}
+	if c.maxSize > 0 {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Size() > c.maxSize {
+			return nil, fmt.Errorf("file exceeds maximum size (%d bytes): %s",
+				c.maxSize, path)
+		}
+	}
+	return ioutil.ReadFile(path)
+}
+
+// customFinder, returns the PosFinder configured for the type of o, or nil
+// if the default PosFinder is used.
+func (c *searchContext) customFinder(o *Object) PosFinder {
+	if fn := c.finders[o.ObjType]; fn != nil {
+		return fn(o)
+	}
+	return nil
+}
+
+// findObject, returns the position and source of the declaration of Object o.
+// For packages the package directory is recorded in o.
+func (c *searchContext) findObject(ctx context.Context, o *Object) (*token.Position, []byte, error) {
+	if o.local {
+		// Local objects are declared in the source file.
+		if p := positionFor(o.pos, c.fset); p != nil {
+			return p, c.src, nil
+		}
+	}
+	if f := c.customFinder(o); f != nil {
+		return c.rankedPosition(ctx, o, f)
+	}
+	if o.ObjType == Package {
+		pos, src, dir, err := c.findPkgDoc(ctx, o.PkgPath)
+		o.PkgDir = dir
+		return pos, src, err
+	}
+	f, err := o.Finder()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return c.rankedPosition(ctx, o, f)
+}
+
+// rankedPosition, searches the package of o using PosFinder f and records the
+// rank of the matching file in o.
+func (c *searchContext) rankedPosition(ctx context.Context, o *Object, f PosFinder) (*token.Position, []byte, error) {
+	res, err := c.searchPackage(ctx, o.PkgPath, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	o.FileRank = &res.rank
+	return res.pos, res.src, nil
+}
+
+// findPkgDoc, returns the position of the package clause of the file containing
+// the package comment of the package with import path pkgPath, preferring
+// "doc.go".  If no file has a package comment the package clause of the first
+// file is returned.  The package directory is also returned.
+func (c *searchContext) findPkgDoc(ctx context.Context, pkgPath string) (*token.Position, []byte, string, error) {
+	dir, err := c.pkgPath(pkgPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	names, err := c.pkgFiles(dir, false)
+	if err != nil {
+		return nil, nil, dir, err
 	}
 	sort.Strings(names)
-	fset := token.NewFileSet()
-	doc := filepath.Join(pkgPath, "doc.go")
-	if n := sort.SearchStrings(names, doc); n < len(names) {
-		af, _ := parser.ParseFile(fset, doc, nil, parser.PackageClauseOnly)
-		if af.Comments != nil && len(af.Comments) != 0 {
-			p := fset.Position(af.Comments[0].Pos())
-			return &p, nil
+	doc := filepath.Join(dir, "doc.go")
+	if n := sort.SearchStrings(names, doc); n < len(names) && names[n] == doc {
+		copy(names[1:n+1], names[:n])
+		names[0] = doc
+	}
+	var first *findRes
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, dir, err
+		}
+		src, err := c.readFile(name)
+		if err != nil {
+			continue
+		}
+		fset := token.NewFileSet()
+		af, _ := parser.ParseFile(fset, name, src,
+			parser.PackageClauseOnly|parser.ParseComments)
+		if af == nil {
+			continue
+		}
+		pos := positionFor(af.Package, fset)
+		if af.Doc != nil {
+			return pos, src, dir, nil
 		}
+		if first == nil {
+			first = &findRes{pos: pos, src: src}
+		}
+	}
+	if first == nil {
+		return nil, nil, dir, fmt.Errorf("no files found for package: %s", pkgPath)
 	}
-	return nil, nil
+	return first.pos, first.src, dir, nil
 }
 
-func (c *context) pkgPath(name string) (string, error) {
-	for _, dir := range c.ctx.SrcDirs() {
+func (c *searchContext) pkgPath(name string) (string, error) {
+	for _, dir := range c.bctx.SrcDirs() {
 		path := filepath.Join(dir, name)
 		if isGoPkgDir(path) {
 			return path, nil
@@ -142,63 +444,115 @@ func (c *context) pkgPath(name string) (string, error) {
 	return "", fmt.Errorf("path not found for pacakge: %s", name)
 }
 
-func (c *context) parseTargetDir() error {
-	// TODO: don't wait for all files to be read before parsing
-	srcs, err := c.readDirSource(c.filename)
+func (c *searchContext) parseTargetDir(ctx context.Context) error {
+	names, err := c.pkgFiles(c.dirname, c.incTest)
 	if err != nil {
 		return err
 	}
-	if len(srcs) != 0 {
-		files, _ := parseDir(srcs, c.fset)
+	n := 0
+	for _, name := range names {
+		if name != c.filename {
+			names[n] = name
+			n++
+		}
+	}
+	if n != 0 {
+		files, _ := c.parseFiles(ctx, c.fset, names[:n])
 		c.files = append(c.files, files...)
 	}
 	return nil
 }
 
-func parseDir(srcs map[string][]byte, fset *token.FileSet) ([]*ast.File, error) {
-	// TODO: don't wait for all files to be read before parsing
+// checkTypes, type checks the package files of c recording the results in
+// info.  An error is returned only if type info is missing or ctx is done
+// before type checking completes.
+//
+// Type checking is not interrupted when ctx is done: the type checker keeps
+// running in its own goroutine, and writing to info, until it completes.  If
+// an error is returned info must not be used.
+func (c *searchContext) checkTypes(ctx context.Context, info *types.Info) (*types.Package, error) {
+	return checkFiles(ctx, c.dirname, c.fset, c.files, info)
+}
+
+func checkFiles(ctx context.Context, path string, fset *token.FileSet, files []*ast.File, info *types.Info) (*types.Package, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type checkRes struct {
+		pkg *types.Package
+		err error
+	}
+	// The type checker cannot be interrupted, so run it in a goroutine and
+	// use a buffered channel to ensure the goroutine always exits.
+	ch := make(chan checkRes, 1)
+	go func() {
+		conf := types.Config{}
+		pkg, err := conf.Check(path, fset, files, info)
+		ch <- checkRes{pkg: pkg, err: err}
+	}()
+	select {
+	case r := <-ch:
+		// Return error only if missing type info.
+		if r.err != nil && len(info.Defs) == 0 && len(info.Uses) == 0 {
+			return nil, r.err
+		}
+		return r.pkg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// parseFiles, reads and parses the named files using the work pool of c.
+func (c *searchContext) parseFiles(ctx context.Context, fset *token.FileSet, names []string) ([]*ast.File, error) {
+	afs := make([]*ast.File, len(names))
+	errs := make([]error, len(names))
+	c.pool.each(ctx, len(names), func(i int) {
+		src, err := c.readFile(names[i])
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		// token.FileSet is safe for concurrent use.
+		afs[i], errs[i] = parser.ParseFile(fset, names[i], src, parser.ParseComments)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	var first error
-	files := make([]*ast.File, 0, len(srcs))
-	mu := new(sync.Mutex)
-	wg := new(sync.WaitGroup)
-	wg.Add(len(srcs))
-	for name, src := range srcs {
-		go func(name string, src []byte) {
-			defer wg.Done()
-			af, err := parser.ParseFile(fset, name, src, parser.ParseComments)
-			mu.Lock()
-			if af != nil {
-				files = append(files, af)
-			}
-			if err != nil && first == nil {
-				first = err
-			}
-			mu.Unlock()
-		}(name, src)
-	}
-	wg.Wait()
+	files := make([]*ast.File, 0, len(afs))
+	for i, af := range afs {
+		if af != nil {
+			files = append(files, af)
+		}
+		if errs[i] != nil && first == nil {
+			first = errs[i]
+		}
+	}
 	return files, first
 }
 
-func (c *context) readDirSource(skip string) (map[string][]byte, error) {
-	// TOOD: Remove
-	names, err := c.pkgFiles(c.dirname)
-	if err != nil {
+// pkgFiles, returns the package files in dir including any overlay files that
+// do not exist on disk.
+func (c *searchContext) pkgFiles(dir string, test bool) ([]string, error) {
+	names, err := pkgFiles(c.bctx, dir, test)
+	extra := c.overlay.dirFiles(dir)
+	if len(extra) == 0 {
+		return names, err
+	}
+	if err != nil && len(names) == 0 && !os.IsNotExist(err) {
 		return nil, err
 	}
-	srcs := make(map[string][]byte)
+	seen := make(map[string]bool, len(names))
 	for _, name := range names {
-		if name != skip {
-			if src, _ := ioutil.ReadFile(name); src != nil {
-				srcs[name] = src
-			}
+		seen[absPath(name)] = true
+	}
+	for _, name := range extra {
+		path := filepath.Join(dir, name)
+		if !seen[absPath(path)] && matchFile(c.bctx, dir, name, test) {
+			names = append(names, path)
 		}
 	}
-	return srcs, nil
-}
-
-func (c *context) pkgFiles(dir string) ([]string, error) {
-	return pkgFiles(c.ctx, dir, c.incTest)
+	return names, nil
 }
 
 func pkgFiles(c *build.Context, dir string, test bool) ([]string, error) {
@@ -231,8 +585,8 @@ func parseFile(filename string, src []byte) (*ast.File, *token.FileSet, error) {
 	return af, fset, nil
 }
 
-func (c *context) MatchFile(dir, name string) (match bool) {
-	return matchFile(c.ctx, dir, name, c.incTest)
+func (c *searchContext) MatchFile(dir, name string) (match bool) {
+	return matchFile(c.bctx, dir, name, c.incTest)
 }
 
 func matchFile(c *build.Context, dir, name string, test bool) (match bool) {
@@ -0,0 +1,51 @@
+package define
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// workPool, limits the number of files read and parsed concurrently.  A single
+// pool is shared by all of the searches for a query, so the limit applies
+// across parsing the target package and searching for an object's position.
+type workPool struct {
+	sem chan struct{}
+}
+
+func newWorkPool(n int) *workPool {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	return &workPool{sem: make(chan struct{}, n)}
+}
+
+// each, calls fn for each i in [0, n) using at most cap(p.sem) goroutines and
+// returns once all calls have completed.  Calls that have not started when ctx
+// is done are skipped.
+func (p *workPool) each(ctx context.Context, n int, fn func(i int)) {
+	workers := cap(p.sem)
+	if n < workers {
+		workers = n
+	}
+	jobs := make(chan int)
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				p.sem <- struct{}{}
+				if ctx.Err() == nil {
+					fn(i)
+				}
+				<-p.sem
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+package define
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testConfig, returns a Config using testdata as its GOPATH.
+func testConfig(t *testing.T) *Config {
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := DefaultConfig
+	conf.Context = build.Default
+	conf.Context.GOPATH = gopath
+	return &conf
+}
+
+// testSource, returns the absolute path of testdata file name, its source and
+// the offset of the first occurrence of sel.
+func testSource(t *testing.T, name, sel string) (string, []byte, int) {
+	path, err := filepath.Abs(filepath.Join("testdata", "src", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	off := bytes.Index(src, []byte(sel))
+	if off == -1 {
+		t.Fatalf("%s: selection not found: %q", name, sel)
+	}
+	return path, src, off
+}
+
+// cancelFinder, cancels the search when its first candidate is checked.
+type cancelFinder struct {
+	cancel context.CancelFunc
+	called *int32
+}
+
+func (f cancelFinder) Candidate(b []byte) bool {
+	atomic.StoreInt32(f.called, 1)
+	f.cancel()
+	return true
+}
+
+func (f cancelFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
+	return nil
+}
+
+func TestCancelSearchNoLeak(t *testing.T) {
+	filename, _, off := testSource(t, "leak/leak.go", "ToLower")
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var called int32
+	conf := testConfig(t)
+	conf.MaxWorkers = 2
+	conf.Finders = map[Type]FinderFunc{
+		Func: func(o *Object) PosFinder {
+			return cancelFinder{cancel: cancel, called: &called}
+		},
+	}
+	conf.DefineContext(ctx, filename, off, nil)
+	if atomic.LoadInt32(&called) == 0 {
+		t.Fatal("search was not started")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines: before %d after %d\n%s", before,
+				runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWorkPoolCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newWorkPool(2)
+	var calls int32
+	p.each(ctx, 100, func(i int) {
+		if atomic.AddInt32(&calls, 1) == 3 {
+			cancel()
+		}
+	})
+	if n := atomic.LoadInt32(&calls); n >= 100 {
+		t.Errorf("calls after cancel were not skipped: %d", n)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("goroutines: before %d after %d", before, n)
+	}
+}
@@ -0,0 +1,281 @@
+package define
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+
+	"golang.org/x/tools/go/types"
+)
+
+// Query, holds the results of parsing the file containing a cursor and of
+// type checking its package, so that several questions may be asked about the
+// cursor without parsing and type checking again.  Type checking is done when
+// first needed and results are cached, as is the parsing of the rest of the
+// package.  The context the Query was created with also bounds the searches
+// made by its methods.
+//
+// A Query is not safe for concurrent use.
+type Query struct {
+	conf     *Config
+	ctx      context.Context
+	filename string
+	sc       *searchContext
+	fset     *token.FileSet
+	af       *ast.File
+	src      []byte
+	node     ast.Node
+	local    *Object // object found without type checking, if any
+
+	checked bool
+	err     error // type checking or lookup error
+	pkg     *types.Package
+	info    *types.Info
+	obj     types.Object
+	sel     *types.Selection
+
+	found bool
+	o     *Object
+	oSrc  []byte
+	oErr  error
+}
+
+func (c *Config) Query(filename string, cursor int, src interface{}) (*Query, error) {
+	return c.QueryContext(context.Background(), filename, cursor, src)
+}
+
+// QueryContext, parses filename and returns a Query for the node at byte
+// offset cursor.  Parsing and searching stop when ctx is done, type checking
+// is not interrupted but its results are discarded.
+func (c *Config) QueryContext(ctx context.Context, filename string, cursor int, src interface{}) (*Query, error) {
+	text, err := c.readSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSelection(text, cursor); err != nil {
+		return nil, err
+	}
+	af, fset, err := parseFile(filename, text)
+	if err != nil {
+		return nil, err
+	}
+	node, err := nodeAtOffset(af, fset, cursor)
+	if err != nil {
+		return nil, err
+	}
+	local, err := localObject(af, fset, node)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{
+		conf:     c,
+		ctx:      ctx,
+		filename: filename,
+		fset:     fset,
+		af:       af,
+		src:      text,
+		node:     node,
+		local:    local,
+	}
+	return q, nil
+}
+
+// searchContext, returns the searchContext of the queried file, parsing the
+// rest of its package when first called.
+func (q *Query) searchContext() *searchContext {
+	if q.sc == nil {
+		q.sc = newContext(q.ctx, q.filename, q.src, q.af, q.fset, q.conf)
+	}
+	return q.sc
+}
+
+// check, type checks the package of the queried file and looks up the object
+// at the cursor, once.
+func (q *Query) check() error {
+	if q.checked {
+		return q.err
+	}
+	q.checked = true
+	info := newTypeInfo(q.node)
+	if info.Implicits == nil {
+		info.Implicits = make(map[ast.Node]types.Object)
+	}
+	if info.Selections == nil {
+		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
+	}
+	q.pkg, q.err = q.searchContext().checkTypes(q.ctx, info)
+	if q.err != nil {
+		return q.err
+	}
+	q.info = info
+	q.obj, q.sel, q.err = lookupType(q.node, info)
+	return q.err
+}
+
+// checkObject, is like check but also returns an error if there is no object
+// at the cursor, such as on a package clause or blank identifier.
+func (q *Query) checkObject() error {
+	if err := q.check(); err != nil {
+		return err
+	}
+	if q.obj == nil {
+		return errors.New("no object found at offset")
+	}
+	return nil
+}
+
+// Filename, returns the name of the queried file.
+func (q *Query) Filename() string { return q.filename }
+
+// Source, returns the source of the queried file.
+func (q *Query) Source() []byte { return q.src }
+
+// File, returns the AST of the queried file.
+func (q *Query) File() *ast.File { return q.af }
+
+// FileSet, returns the FileSet of the queried file and its package.
+func (q *Query) FileSet() *token.FileSet { return q.fset }
+
+// Node, returns the AST node at the cursor.
+func (q *Query) Node() ast.Node { return q.node }
+
+// Package, returns the type checked package of the queried file.
+func (q *Query) Package() (*types.Package, error) {
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+	return q.pkg, nil
+}
+
+// Info, returns the type information of the package of the queried file.
+func (q *Query) Info() (*types.Info, error) {
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+	return q.info, nil
+}
+
+// TypesObject, returns the object at the cursor and, if the cursor is on a
+// selector, its selection.
+func (q *Query) TypesObject() (types.Object, *types.Selection, error) {
+	if err := q.check(); err != nil {
+		return nil, nil, err
+	}
+	return q.obj, q.sel, nil
+}
+
+// Object, returns the definition of the object at the cursor and the source of
+// the file containing it.  The result is cached.
+func (q *Query) Object() (*Object, []byte, error) {
+	if !q.found {
+		q.found = true
+		q.o, q.oSrc, q.oErr = q.findObject()
+		if o := q.o; o != nil {
+			o.PkgPath = q.importPath(o.PkgPath)
+			// Definitions not found by searching the package, such as
+			// those of the queried package, are ranked here.
+			if o.FileRank == nil && o.Unadjusted.Filename != "" {
+				o.FileRank = q.baseContext().fileRank(o.Unadjusted.Filename)
+			}
+		}
+	}
+	return q.o, q.oSrc, q.oErr
+}
+
+// Position, returns the position of the definition of the object at the
+// cursor and the source of the file containing it.
+func (q *Query) Position() (*Position, []byte, error) {
+	o, src, err := q.Object()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &o.Position, src, nil
+}
+
+func (q *Query) findObject() (*Object, []byte, error) {
+	c := q.conf
+	if o := q.local; o != nil {
+		o.PkgPath = filepath.Dir(filepath.Clean(q.filename))
+		c.setPosition(o, token.Position(o.Position), q.src)
+		return o, q.src, nil
+	}
+	if err := q.checkObject(); err != nil {
+		return nil, nil, err
+	}
+	if o := namedResult(q.af, q.fset, q.node, q.obj, q.info); o != nil {
+		o.PkgPath = q.sc.dirname
+		c.setPosition(o, token.Position(o.Position), q.src)
+		return o, q.src, nil
+	}
+	o, err := newObject(q.obj, q.sel)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tp *token.Position
+	var objSrc []byte
+	if o.ObjType != Package && q.obj.Pkg() == q.pkg && q.sc.customFinder(o) == nil {
+		// Objects of the queried package are located using the type
+		// checker, which resolves method receivers exactly, unless a
+		// custom finder is configured.
+		tp, objSrc = q.typesPosition()
+	}
+	if tp == nil {
+		tp, objSrc, err = q.sc.findObject(q.ctx, o)
+		if err != nil {
+			if o.pos.IsValid() {
+				tp = positionFor(o.pos, q.fset)
+			}
+			if tp == nil {
+				return nil, nil, err
+			}
+		}
+	}
+	tp, objSrc, o.Hops = q.sc.followLinkname(q.ctx, o, tp, objSrc)
+	tp, objSrc = q.sc.followAsm(o, tp, objSrc)
+	if tp != nil {
+		b := objSrc
+		if b == nil && tp.Filename == q.filename {
+			b = q.src
+		}
+		c.setPosition(o, *tp, b)
+	}
+	return o, objSrc, nil
+}
+
+// importPath, returns the import path of the package with path, which is its
+// directory if it is the queried package.
+func (q *Query) importPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	return q.baseContext().importPath(path)
+}
+
+// baseContext, returns the searchContext of the query if the package has been
+// parsed, otherwise a searchContext that does not require parsing it.
+func (q *Query) baseContext() *searchContext {
+	if q.sc != nil {
+		return q.sc
+	}
+	return newSearchContext(q.conf)
+}
+
+// typesPosition, returns the position and source of the declaration of the
+// queried object recorded by the type checker, if it is in the queried
+// package.
+func (q *Query) typesPosition() (*token.Position, []byte) {
+	p := positionFor(q.obj.Pos(), q.fset)
+	if p == nil {
+		return nil, nil
+	}
+	if p.Filename == q.filename {
+		return p, q.src
+	}
+	b, err := q.sc.readFile(p.Filename)
+	if err != nil {
+		return nil, nil
+	}
+	return p, b
+}
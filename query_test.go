@@ -0,0 +1,95 @@
+package define
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+)
+
+func TestQueryNoObject(t *testing.T) {
+	conf := testConfig(t)
+	for _, sel := range []string{"query\n", "t := v", "_ = t"} {
+		path, src, off := testSource(t, "query/query.go", sel)
+		if _, _, err := conf.Object(path, off, src); err == nil {
+			t.Errorf("%q: expected error for cursor without object", sel)
+		}
+		q, err := conf.Query(path, off, src)
+		if err != nil {
+			t.Fatalf("%q: %s", sel, err)
+		}
+		if _, _, err := q.Position(); err == nil {
+			t.Errorf("%q: expected error for cursor without object", sel)
+		}
+	}
+}
+
+func TestQueryLocalNotParsed(t *testing.T) {
+	conf := testConfig(t)
+	for _, sel := range []string{"loop\n\t\t}", "return\n}"} {
+		path, src, off := testSource(t, "query/query.go", sel)
+		q, err := conf.Query(path, off, src)
+		if err != nil {
+			t.Fatalf("%q: %s", sel, err)
+		}
+		if _, _, err := q.Object(); err != nil {
+			t.Fatalf("%q: %s", sel, err)
+		}
+		if q.sc != nil {
+			t.Errorf("%q: package parsed for local object", sel)
+		}
+	}
+}
+
+// pkgClauseFinder, finds the package clause of every file.
+type pkgClauseFinder struct{}
+
+func (pkgClauseFinder) Candidate(b []byte) bool { return true }
+
+func (pkgClauseFinder) Find(af *ast.File, fset *token.FileSet) *token.Position {
+	p := fset.Position(af.Package)
+	return &p
+}
+
+func TestQueryMethodFinder(t *testing.T) {
+	conf := testConfig(t)
+	conf.Finders = map[Type]FinderFunc{
+		Method: func(o *Object) PosFinder { return pkgClauseFinder{} },
+	}
+	path, src, off := testSource(t, "rename/rename.go", "Area() int {")
+	pos, _, err := conf.Define(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Line != 1 {
+		t.Errorf("got line %d, want the custom finder's line 1", pos.Line)
+	}
+}
+
+func TestQueryReuse(t *testing.T) {
+	conf := testConfig(t)
+	path, src, off := testSource(t, "rename/rename.go", "Count(1)")
+	q, err := conf.Query(path, off, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := q.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Highlights(); err != nil {
+		t.Fatal(err)
+	}
+	callers, err := q.Callers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(callers) != 1 || callers[0].Func.Name != "Print" {
+		t.Errorf("got callers %+v, want Print", callers)
+	}
+	if _, err := q.Rename("Sum"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Info(); got != info {
+		t.Error("package type checked again")
+	}
+}
@@ -0,0 +1,542 @@
+package define
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"unicode"
+
+	"golang.org/x/tools/go/types"
+)
+
+// Edit, is a replacement of text in a file.
+type Edit struct {
+	Offset int    `json:"offset"` // byte offset, starting at 0
+	Length int    `json:"length"` // length in bytes of the text to replace
+	Text   string `json:"text"`   // replacement text
+}
+
+func (c *Config) Rename(filename string, cursor int, newName string) (map[string][]Edit, error) {
+	return c.RenameContext(context.Background(), filename, cursor, newName)
+}
+
+// RenameContext, returns the edits, keyed by filename, required to rename the
+// object at the cursor to newName.  The package of filename and the packages of
+// the Config's Workspace are searched for references.  The edits are not
+// applied.  An error is returned if the rename would conflict with existing
+// declarations or change the meaning of the program.
+func (c *Config) RenameContext(ctx context.Context, filename string, cursor int, newName string) (map[string][]Edit, error) {
+	q, err := c.QueryContext(ctx, filename, cursor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return q.Rename(newName)
+}
+
+// Rename, is like Config.RenameContext for the object at the cursor of the
+// Query.
+func (q *Query) Rename(newName string) (map[string][]Edit, error) {
+	// The symbolic variable of a type switch has no object, so the query
+	// is not required to find one.
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+	r, err := newRenamer(q.ctx, q, newName, q.conf.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	return r.edits(), nil
+}
+
+type renamer struct {
+	sc   *searchContext
+	obj  types.Object // renamed object, in the defining package
+	path objectPath
+	from string
+	to   string
+	def  *typedPackage   // defining package
+	pkgs []*typedPackage // searched packages, def is first
+
+	guard   *ast.Ident     // symbolic variable of the type switch declaring obj, if any
+	clauses []types.Object // objects declared by the type switch in each case clause
+}
+
+func newRenamer(ctx context.Context, q *Query, to string, workspace []string) (*renamer, error) {
+	obj := q.obj
+	var guard *ast.Ident
+	var clauses []types.Object
+	if id, ok := q.node.(*ast.Ident); ok {
+		guard, clauses = typeSwitchVar(q.af, q.info, id, obj)
+		if obj == nil && len(clauses) != 0 {
+			obj = clauses[0]
+		}
+	}
+	switch {
+	case obj == nil:
+		return nil, errors.New("no object found at offset")
+	case obj.Pkg() == nil:
+		return nil, fmt.Errorf("cannot rename builtin: %s", obj.Name())
+	case !isIdentifier(to) || to == "_":
+		return nil, fmt.Errorf("invalid identifier: %q", to)
+	case obj.Name() == to:
+		return nil, fmt.Errorf("object is already named: %s", to)
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		return nil, errors.New("cannot rename package names")
+	}
+	r := &renamer{
+		sc:      q.sc,
+		guard:   guard,
+		clauses: clauses,
+		from:    obj.Name(),
+		to:      to,
+	}
+	path, exported := q.sc.pathOf(obj)
+	exported = exported && obj.Exported()
+	r.path = path
+
+	qp := q.queryPackage()
+	if obj.Pkg() == q.pkg {
+		r.def = qp
+		r.obj = obj
+	} else {
+		dir, err := q.sc.pkgPath(path.pkg)
+		if err != nil {
+			return nil, err
+		}
+		if r.def, err = q.sc.checkPackage(ctx, dir); err != nil {
+			return nil, err
+		}
+		if r.obj = path.lookup(r.def.pkg); r.obj == nil {
+			return nil, fmt.Errorf("declaration not found: %s", obj.Name())
+		}
+	}
+	r.pkgs = append(r.pkgs, r.def)
+	if !exported {
+		return r, nil
+	}
+	if qp != r.def {
+		r.pkgs = append(r.pkgs, qp)
+	}
+	skip := map[string]bool{
+		absPath(r.def.dir): true,
+		absPath(qp.dir):    true,
+	}
+	for _, dir := range q.sc.workspaceDirs(workspace, r.def.dir) {
+		if skip[absPath(dir)] {
+			continue
+		}
+		p, err := q.sc.checkPackage(ctx, dir)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		r.pkgs = append(r.pkgs, p)
+	}
+	return r, nil
+}
+
+// target, returns the renamed object as seen by package p.
+func (r *renamer) target(p *typedPackage) types.Object {
+	if p == r.def {
+		return r.obj
+	}
+	for _, imp := range p.pkg.Imports() {
+		if r.sc.pkgKey(imp) == r.path.pkg {
+			return r.path.lookup(imp)
+		}
+	}
+	return nil
+}
+
+// refs, returns the identifiers in p that declare or refer to the renamed
+// object.
+func (r *renamer) refs(p *typedPackage) []*ast.Ident {
+	obj := r.target(p)
+	if obj == nil {
+		return nil
+	}
+	var ids []*ast.Ident
+	if r.guard != nil && p == r.def {
+		ids = append(ids, r.guard)
+	}
+	for id, o := range p.info.Defs {
+		if r.renames(p, o, obj) {
+			ids = append(ids, id)
+		}
+	}
+	for id, o := range p.info.Uses {
+		if r.renames(p, o, obj) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// renames, reports if o, an object of package p, is the renamed object obj or
+// is declared by the same type switch.
+func (r *renamer) renames(p *typedPackage, o, obj types.Object) bool {
+	if o == obj {
+		return true
+	}
+	if p == r.def {
+		for _, c := range r.clauses {
+			if o == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeSwitchVar, returns the symbolic variable of the type switch in af that
+// declares id, or obj in one of its case clauses, and the objects it declares
+// in each case clause.
+func typeSwitchVar(af *ast.File, info *types.Info, id *ast.Ident, obj types.Object) (*ast.Ident, []types.Object) {
+	var guard *ast.Ident
+	var clauses []types.Object
+	ast.Inspect(af, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSwitchStmt)
+		if !ok || guard != nil {
+			return guard == nil
+		}
+		as, ok := ts.Assign.(*ast.AssignStmt)
+		if !ok || len(as.Lhs) != 1 {
+			return true
+		}
+		lhs, ok := as.Lhs[0].(*ast.Ident)
+		if !ok {
+			return true
+		}
+		var objs []types.Object
+		found := lhs == id
+		for _, s := range ts.Body.List {
+			if o := info.Implicits[s]; o != nil {
+				objs = append(objs, o)
+				found = found || (obj != nil && o == obj)
+			}
+		}
+		if found {
+			guard, clauses = lhs, objs
+			return false
+		}
+		return true
+	})
+	return guard, clauses
+}
+
+func (r *renamer) edits() map[string][]Edit {
+	edits := make(map[string][]Edit)
+	seen := make(map[token.Position]bool)
+	for _, p := range r.pkgs {
+		for _, id := range r.refs(p) {
+			pos := p.fset.PositionFor(id.Pos(), false)
+			if seen[pos] {
+				continue
+			}
+			seen[pos] = true
+			edits[pos.Filename] = append(edits[pos.Filename], Edit{
+				Offset: pos.Offset,
+				Length: len(r.from),
+				Text:   r.to,
+			})
+		}
+	}
+	for _, e := range edits {
+		sort.Sort(byOffset(e))
+	}
+	return edits
+}
+
+type byOffset []Edit
+
+func (e byOffset) Len() int           { return len(e) }
+func (e byOffset) Less(i, j int) bool { return e[i].Offset < e[j].Offset }
+func (e byOffset) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// check, reports if renaming the object would result in a conflict.
+func (r *renamer) check() error {
+	if err := r.checkExport(); err != nil {
+		return err
+	}
+	if err := r.checkEmbedded(); err != nil {
+		return err
+	}
+	if r.path.parent != "" {
+		return r.checkMember()
+	}
+	return r.checkScope()
+}
+
+// checkExport, reports if an exported object referenced by other packages
+// would become unexported.
+func (r *renamer) checkExport() error {
+	if !r.obj.Exported() || ast.IsExported(r.to) {
+		return nil
+	}
+	for _, p := range r.pkgs {
+		if p != r.def && len(r.refs(p)) != 0 {
+			return fmt.Errorf("renaming %s to %s would make it unexported, "+
+				"but it is referenced by package: %s", r.from, r.to, p.pkg.Path())
+		}
+	}
+	return nil
+}
+
+// checkEmbedded, reports if the renamed type is embedded in a struct, which
+// would also rename the implicit field.
+func (r *renamer) checkEmbedded() error {
+	if _, ok := r.obj.(*types.TypeName); !ok {
+		return nil
+	}
+	for _, p := range r.pkgs {
+		obj := r.target(p)
+		for id, o := range p.info.Defs {
+			if v, ok := o.(*types.Var); ok && v.Anonymous() && p.info.Uses[id] == obj {
+				return fmt.Errorf("cannot rename %s: embedded in struct at %s",
+					r.from, p.fset.Position(id.Pos()))
+			}
+		}
+	}
+	return nil
+}
+
+// checkMember, reports conflicts when renaming a field or method.
+func (r *renamer) checkMember() error {
+	named := r.parent(r.def)
+	if named == nil {
+		return fmt.Errorf("type not found: %s", r.path.parent)
+	}
+	if obj, _, _ := types.LookupFieldOrMethod(named, true, r.def.pkg, r.to); obj != nil {
+		return fmt.Errorf("renaming %s.%s would conflict with %s.%s",
+			r.path.parent, r.from, r.path.parent, r.to)
+	}
+	if _, ok := r.obj.(*types.Func); !ok {
+		return nil
+	}
+	for _, p := range r.pkgs {
+		t := r.parent(p)
+		if t == nil {
+			continue
+		}
+		if iface, ok := t.Underlying().(*types.Interface); ok {
+			if impl := implementation(p, iface); impl != nil {
+				return fmt.Errorf("renaming %s.%s would break its implementation by %s",
+					r.path.parent, r.from, impl.Obj().Name())
+			}
+			continue
+		}
+		if iface := implemented(p, t, r.from); iface != nil {
+			return fmt.Errorf("renaming %s.%s would stop %s from implementing %s",
+				r.path.parent, r.from, r.path.parent, iface.Obj().Name())
+		}
+	}
+	return nil
+}
+
+// parent, returns the named type declaring the renamed field or method as seen
+// by package p.
+func (r *renamer) parent(p *typedPackage) *types.Named {
+	pkg := p.pkg
+	if p != r.def {
+		pkg = nil
+		for _, imp := range p.pkg.Imports() {
+			if r.sc.pkgKey(imp) == r.path.pkg {
+				pkg = imp
+				break
+			}
+		}
+		if pkg == nil {
+			return nil
+		}
+	}
+	if tn, ok := pkg.Scope().Lookup(r.path.parent).(*types.TypeName); ok {
+		named, _ := tn.Type().(*types.Named)
+		return named
+	}
+	return nil
+}
+
+// implementation, returns a package level concrete type of p that implements
+// interface iface.
+func implementation(p *typedPackage, iface *types.Interface) *types.Named {
+	scope := p.pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || types.IsInterface(named) {
+			continue
+		}
+		if types.Implements(named, iface) || types.Implements(types.NewPointer(named), iface) {
+			return named
+		}
+	}
+	return nil
+}
+
+// implemented, returns a package level interface of p, with a method named
+// method, that is implemented by t.
+func implemented(p *typedPackage, t *types.Named, method string) *types.Named {
+	scope := p.pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		iface, ok := named.Underlying().(*types.Interface)
+		if !ok || iface.NumMethods() == 0 {
+			continue
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			if iface.Method(i).Name() != method {
+				continue
+			}
+			if types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface) {
+				return named
+			}
+		}
+	}
+	return nil
+}
+
+// checkScope, reports conflicts when renaming package level and local
+// objects: redeclarations, references that would be shadowed by another
+// declaration and references to other objects that would be captured by the
+// renamed object.
+func (r *renamer) checkScope() error {
+	if r.clauses == nil {
+		return r.checkObjectScope(r.obj)
+	}
+	for _, obj := range r.clauses {
+		if err := r.checkObjectScope(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkObjectScope, is like checkScope for the renamed object o, which
+// differs from r.obj for the case clauses of a type switch.
+func (r *renamer) checkObjectScope(o types.Object) error {
+	scope := o.Parent()
+	if scope == nil {
+		return fmt.Errorf("cannot rename %s: no scope", r.from)
+	}
+	if obj := scope.Lookup(r.to); obj != nil {
+		return fmt.Errorf("renaming %s would conflict with declaration of %s at %s",
+			r.from, r.to, r.def.fset.Position(obj.Pos()))
+	}
+	if scope == r.def.pkg.Scope() {
+		if err := r.checkImports(); err != nil {
+			return err
+		}
+	}
+	for _, id := range r.refs(r.def) {
+		if r.def.info.Defs[id] != nil {
+			continue
+		}
+		s := r.def.pkg.Scope().Innermost(id.Pos())
+		if s == nil {
+			continue
+		}
+		if _, obj := s.LookupParent(r.to, id.Pos()); obj != nil && within(obj.Parent(), scope) {
+			return fmt.Errorf("renaming %s would cause the reference at %s to refer to %s",
+				r.from, r.def.fset.Position(id.Pos()), r.to)
+		}
+	}
+	for id, obj := range r.def.info.Uses {
+		if id.Name != r.to || obj.Parent() == nil {
+			continue
+		}
+		if obj.Parent() == scope || !within(scope, obj.Parent()) {
+			continue
+		}
+		if scopeContains(scope, id.Pos()) && (scope == r.def.pkg.Scope() || o.Pos() < id.Pos()) {
+			return fmt.Errorf("renaming %s would shadow the reference to %s at %s",
+				r.from, r.to, r.def.fset.Position(id.Pos()))
+		}
+	}
+	return nil
+}
+
+// checkImports, reports if a package level object would conflict with the
+// name of a package imported by a file in the package.
+func (r *renamer) checkImports() error {
+	for _, af := range r.def.files {
+		for _, spec := range af.Imports {
+			var name string
+			if spec.Name != nil {
+				name = spec.Name.Name
+			} else if pn, ok := r.def.info.Implicits[spec].(*types.PkgName); ok {
+				name = pn.Name()
+			} else {
+				name = importName(r.def.pkg, spec)
+			}
+			if name == r.to {
+				return fmt.Errorf("renaming %s would conflict with import at %s",
+					r.from, r.def.fset.Position(spec.Pos()))
+			}
+		}
+	}
+	return nil
+}
+
+// importName, returns the name of the package imported by spec.
+func importName(pkg *types.Package, spec *ast.ImportSpec) string {
+	path := spec.Path.Value
+	if len(path) >= 2 {
+		path = path[1 : len(path)-1]
+	}
+	for _, imp := range pkg.Imports() {
+		if imp.Path() == path {
+			return imp.Name()
+		}
+	}
+	return ""
+}
+
+// within, reports if scope inner is, or is nested within, scope outer.
+func within(inner, outer *types.Scope) bool {
+	for s := inner; s != nil; s = s.Parent() {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeContains, reports if pos is within scope s.  Package scopes do not
+// have a position and contain all positions.
+func scopeContains(s *types.Scope, pos token.Pos) bool {
+	if !s.Pos().IsValid() {
+		return true
+	}
+	return s.Contains(pos)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || token.Lookup(s).IsKeyword() {
+		return false
+	}
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
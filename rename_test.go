@@ -0,0 +1,90 @@
+package define
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// indexAll, returns the offsets of each occurrence of sub in b.
+func indexAll(b []byte, sub string) []int {
+	var offs []int
+	for off := 0; ; {
+		n := bytes.Index(b[off:], []byte(sub))
+		if n == -1 {
+			return offs
+		}
+		offs = append(offs, off+n)
+		off += n + len(sub)
+	}
+}
+
+func TestRename(t *testing.T) {
+	filename, src, _ := testSource(t, "rename/rename.go", "package")
+	var edits []Edit
+	for _, off := range indexAll(src, "Count(") {
+		edits = append(edits, Edit{Offset: off, Length: len("Count"), Text: "Sum"})
+	}
+	conf := testConfig(t)
+	got, err := conf.Rename(filename, bytes.Index(src, []byte("Count(1)")), "Sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]Edit{filename: edits}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rename: got %+v want %+v", got, want)
+	}
+}
+
+func TestRenameTypeSwitch(t *testing.T) {
+	filename, src, start := testSource(t, "rename/rename.go", "switch t :=")
+	var edits []Edit
+	for _, sel := range []string{"t :=", "t\n", "(t)"} {
+		off := start + bytes.Index(src[start:], []byte(sel))
+		if sel == "(t)" {
+			off++
+		}
+		edits = append(edits, Edit{Offset: off, Length: 1, Text: "u"})
+	}
+	want := map[string][]Edit{filename: edits}
+	conf := testConfig(t)
+	// The cursor on the symbolic variable and on a reference in a clause.
+	for _, off := range []int{edits[0].Offset, edits[1].Offset} {
+		got, err := conf.Rename(filename, off, "u")
+		if err != nil {
+			t.Fatalf("Rename(%d): %s", off, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Rename(%d): got %+v want %+v", off, got, want)
+		}
+	}
+}
+
+func TestRenameConflicts(t *testing.T) {
+	tests := []struct {
+		sel  string // selection, the cursor is on its first character
+		to   string
+		want string // error substring
+	}{
+		// Redeclaration in the same scope.
+		{"total := n", "n", "would conflict with declaration of n"},
+		// The loop variable would shadow the reference to total.
+		{"i := 0", "total", "would shadow the reference to total"},
+		// Conflict with the name of an import.
+		{"Count(n int)", "fmt", "would conflict with import"},
+		// Square would no longer implement Shape.
+		{"Area() int {", "Size", "would stop Square from implementing Shape"},
+		// The variable of the int clause would shadow fmt.
+		{"t := v", "fmt", "would shadow the reference to fmt"},
+	}
+	conf := testConfig(t)
+	for _, test := range tests {
+		filename, _, off := testSource(t, "rename/rename.go", test.sel)
+		_, err := conf.Rename(filename, off, test.to)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Rename(%q, %q): got error %v want %q", test.sel,
+				test.to, err, test.want)
+		}
+	}
+}
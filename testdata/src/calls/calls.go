@@ -0,0 +1,9 @@
+package calls
+
+import "strings"
+
+func init() { Helper() }
+
+func init() { Helper() }
+
+func Helper() string { return strings.ToUpper("x") }
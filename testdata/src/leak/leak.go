@@ -0,0 +1,5 @@
+package leak
+
+import "strings"
+
+var Lower = strings.ToLower("LEAK")
@@ -0,0 +1,26 @@
+package query
+
+func Sum(xs []int) (n int) {
+loop:
+	for _, x := range xs {
+		if x < 0 {
+			break loop
+		}
+		n += x
+	}
+	return
+}
+
+func Kind(v interface{}) string {
+	switch t := v.(type) {
+	case int:
+		_ = t
+		return "int"
+	}
+	return ""
+}
+
+// Pair, is summed by [Sum].
+type Pair struct{ A, B int }
+
+var P = Pair{A: 1}
@@ -0,0 +1,5 @@
+package rank_test
+
+func Helper() {}
+
+func Internal() {}
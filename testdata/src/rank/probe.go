@@ -0,0 +1,3 @@
+package rank
+
+func Probe() { Rank() }
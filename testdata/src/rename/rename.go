@@ -0,0 +1,33 @@
+package rename
+
+import "fmt"
+
+type Shape interface {
+	Area() int
+}
+
+type Square struct{ Side int }
+
+func (s Square) Area() int { return s.Side * s.Side }
+
+var _ Shape = Square{}
+
+func Count(n int) int {
+	total := n
+	for i := 0; i < n; i++ {
+		total += i
+	}
+	return total
+}
+
+func Print() { fmt.Println(Count(1)) }
+
+func Describe(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case int:
+		return fmt.Sprint(t)
+	}
+	return ""
+}
@@ -0,0 +1,255 @@
+package define
+
+import (
+	"context"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/go/types"
+)
+
+// typedPackage, is a parsed and type checked package.
+type typedPackage struct {
+	dir   string
+	pkg   *types.Package
+	info  *types.Info
+	files []*ast.File
+	fset  *token.FileSet
+}
+
+// queryPackage, returns the package of the queried file as a typedPackage.
+func (q *Query) queryPackage() *typedPackage {
+	return &typedPackage{
+		dir:   q.sc.dirname,
+		pkg:   q.pkg,
+		info:  q.info,
+		files: q.sc.files,
+		fset:  q.fset,
+	}
+}
+
+// checkPackage, parses and type checks the package in directory dir.  Only
+// the Defs and Uses of the types.Info are recorded.
+func (c *searchContext) checkPackage(ctx context.Context, dir string) (*typedPackage, error) {
+	names, err := c.pkgFiles(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	files, _ := c.parseFiles(ctx, fset, names)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	pkg, err := checkFiles(ctx, c.importPath(dir), fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+	p := &typedPackage{
+		dir:   dir,
+		pkg:   pkg,
+		info:  info,
+		files: files,
+		fset:  fset,
+	}
+	return p, nil
+}
+
+// workspaceDirs, returns the package directories found by walking the
+// workspace roots, excluding directory skip.
+func (c *searchContext) workspaceDirs(roots []string, skip string) []string {
+	seen := map[string]bool{absPath(skip): true}
+	var dirs []string
+	for _, root := range roots {
+		filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.IsDir() {
+				return nil
+			}
+			if path != root {
+				switch name := fi.Name(); {
+				case name == "testdata", name[0] == '.', name[0] == '_':
+					return filepath.SkipDir
+				}
+			}
+			if abs := absPath(path); !seen[abs] && isGoPkgDir(path) {
+				seen[abs] = true
+				dirs = append(dirs, path)
+			}
+			return nil
+		})
+	}
+	return dirs
+}
+
+// importPath, returns the import path of the package in directory dir, or dir
+// if the import path cannot be determined.
+func (c *searchContext) importPath(dir string) string {
+	if p, ok := c.importPaths[dir]; ok {
+		return p
+	}
+	path := dir
+	if pkg, err := c.bctx.ImportDir(dir, build.FindOnly); err == nil {
+		if pkg.ImportPath != "" && pkg.ImportPath != "." {
+			path = pkg.ImportPath
+		}
+	}
+	if c.importPaths == nil {
+		c.importPaths = make(map[string]string)
+	}
+	c.importPaths[dir] = path
+	return path
+}
+
+// pkgKey, returns the import path of package p.  The package of the queried
+// file is type checked using its directory as the path, this is mapped back
+// to its import path so that objects compare equal across packages.
+func (c *searchContext) pkgKey(p *types.Package) string {
+	if p == nil {
+		return ""
+	}
+	if path := p.Path(); filepath.IsAbs(path) {
+		return c.importPath(path)
+	}
+	return p.Path()
+}
+
+// objectKey, returns a key identifying obj that is stable across separately
+// type checked packages.
+func (c *searchContext) objectKey(obj types.Object) string {
+	key := c.pkgKey(obj.Pkg()) + "."
+	if fn, ok := obj.(*types.Func); ok {
+		if sig, ok := fn.Type().(*types.Signature); ok && sig.Recv() != nil {
+			if t, ok := derefType(sig.Recv().Type()).(*types.Named); ok {
+				key += t.Obj().Name() + "."
+			}
+		}
+	}
+	return key + obj.Name()
+}
+
+// objectPath, identifies a package level object, or a field or method of a
+// package level named type, independently of the type checker that created
+// it.
+type objectPath struct {
+	pkg    string // import path
+	parent string // named type, only set for fields and methods
+	name   string
+}
+
+// pathOf, returns the objectPath of obj and reports if obj can be referenced
+// by other packages.
+func (c *searchContext) pathOf(obj types.Object) (objectPath, bool) {
+	pkg := obj.Pkg()
+	if pkg == nil {
+		return objectPath{}, false
+	}
+	p := objectPath{pkg: c.pkgKey(pkg), name: obj.Name()}
+	switch o := obj.(type) {
+	case *types.Func:
+		if sig, ok := o.Type().(*types.Signature); ok && sig.Recv() != nil {
+			t, ok := derefType(sig.Recv().Type()).(*types.Named)
+			if !ok {
+				return p, false
+			}
+			p.parent = t.Obj().Name()
+			return p, true
+		}
+	case *types.Var:
+		if o.IsField() {
+			t, path := fieldParent(pkg, o)
+			if t == nil || len(path) != 0 {
+				return p, false
+			}
+			p.parent = t.Obj().Name()
+			return p, true
+		}
+	}
+	return p, pkg.Scope().Lookup(obj.Name()) == obj
+}
+
+// lookup, returns the object identified by p in package pkg.
+func (p objectPath) lookup(pkg *types.Package) types.Object {
+	if pkg == nil {
+		return nil
+	}
+	if p.parent == "" {
+		return pkg.Scope().Lookup(p.name)
+	}
+	tn, ok := pkg.Scope().Lookup(p.parent).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		if m := named.Method(i); m.Name() == p.name {
+			return m
+		}
+	}
+	switch t := named.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if f := t.Field(i); f.Name() == p.name {
+				return f
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			if m := t.ExplicitMethod(i); m.Name() == p.name {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// fieldParent, returns the package level named struct type of pkg that
+// declares field v, and the names of the fields of the anonymous structs
+// enclosing v, if any.
+func fieldParent(pkg *types.Package, v *types.Var) (*types.Named, []string) {
+	if pkg == nil {
+		return nil, nil
+	}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if st, ok := named.Underlying().(*types.Struct); ok {
+			if path, ok := structPath(st, v, nil); ok {
+				return named, path
+			}
+		}
+	}
+	return nil, nil
+}
+
+// structPath, returns the path of field v in struct st through the fields
+// of nested anonymous structs.
+func structPath(st *types.Struct, v *types.Var, path []string) ([]string, bool) {
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f == v {
+			return path, true
+		}
+		if nested, ok := derefType(f.Type()).(*types.Struct); ok {
+			if p, ok := structPath(nested, v, append(path, f.Name())); ok {
+				return p, true
+			}
+		}
+	}
+	return nil, false
+}